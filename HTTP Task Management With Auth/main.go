@@ -3,12 +3,13 @@ package main
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"flag"
 	"log"
 	"net/http"
-	"strconv"
 	"time"
 
+	"github.com/dgrijalva/jwt-go"
 	_ "github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -189,7 +190,7 @@ func (tm *TaskManager) ListTasks() ([]*Task, error) {
 // Middleware for logging requests
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.Method, r.RequestURI, r.RemoteAddr)
+		log.Printf("%s %s %s %s", requestID(r), r.Method, r.RequestURI, r.RemoteAddr)
 		next.ServeHTTP(w, r)
 	})
 }
@@ -199,7 +200,7 @@ func authenticateMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenStr := r.Header.Get("Authorization")
 		if tokenStr == "" {
-			http.Error(w, "Missing token", http.StatusUnauthorized)
+			httpError(w, r, http.StatusUnauthorized, "error.missing_token")
 			return
 		}
 
@@ -208,7 +209,7 @@ func authenticateMiddleware(next http.Handler) http.Handler {
 			return jwtKey, nil
 		})
 		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			httpError(w, r, http.StatusUnauthorized, "error.invalid_token")
 			return
 		}
 
@@ -217,42 +218,65 @@ func authenticateMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
+	lang := flag.String("lang", "", "default language of the error messages (en or es); defaults to LC_ALL, LC_MESSAGES or LANG")
+	serverConfig := RegisterServerFlags(flag.CommandLine)
+	flag.Parse()
+	serverLocale = SelectLocale(*lang)
+
 	db, err := sql.Open("sqlite3", "./tasks.db")
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
 
 	tm := NewTaskManager(db)
 	if err := tm.InitializeDB(); err != nil {
 		log.Fatal(err)
 	}
 
-	http.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
+	// Wrap the router with the request ID and logging middleware; the task
+	// routes also require a token
+	loggedRouter := requestIDMiddleware(loggingMiddleware(newRouter(tm)))
+
+	serveErr := serverConfig.ListenAndServe(loggedRouter)
+
+	// No request uses the database once the server has stopped
+	if err := db.Close(); err != nil {
+		log.Printf("could not close database: %v", err)
+	}
+	if serveErr != nil {
+		log.Fatalf("server stopped: %v\n", serveErr)
+	}
+}
+
+// newRouter declares the routes of the task API. Registering, logging in and
+// the documentation are public; the task routes are in a group behind
+// authenticateMiddleware.
+func newRouter(tm *TaskManager) *Router {
+	router := NewRouter()
+
+	router.HandleFunc("POST /register", func(w http.ResponseWriter, r *http.Request) {
 		var creds Credentials
-		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if !decodeBody(w, r, &creds) {
 			return
 		}
 
 		user, err := tm.AddUser(creds.Username, creds.Password)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			internalError(w, r, err)
 			return
 		}
 		jsonResponse(w, user, http.StatusCreated)
 	})
 
-	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+	router.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
 		var creds Credentials
-		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if !decodeBody(w, r, &creds) {
 			return
 		}
 
 		user, err := tm.AuthenticateUser(creds.Username, creds.Password)
 		if err != nil {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			httpError(w, r, http.StatusUnauthorized, "error.invalid_credentials")
 			return
 		}
 
@@ -267,85 +291,85 @@ func main() {
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 		tokenStr, err := token.SignedString(jwtKey)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			internalError(w, r, err)
 			return
 		}
 
 		jsonResponse(w, map[string]string{"token": tokenStr}, http.StatusOK)
 	})
 
-	http.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case "GET":
-			tasks, err := tm.ListTasks()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			jsonResponse(w, tasks, http.StatusOK)
-		case "POST":
-			var task Task
-			if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			newTask, err := tm.AddTask(task.Description)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			jsonResponse(w, newTask, http.StatusCreated)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	authenticated := router.Group(authenticateMiddleware)
+
+	authenticated.HandleFunc("GET /tasks", func(w http.ResponseWriter, r *http.Request) {
+		tasks, err := tm.ListTasks()
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		jsonResponse(w, tasks, http.StatusOK)
+	})
+
+	authenticated.HandleFunc("POST /tasks", func(w http.ResponseWriter, r *http.Request) {
+		var task Task
+		if !decodeBody(w, r, &task) {
+			return
+		}
+		newTask, err := tm.AddTask(task.Description)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		jsonResponse(w, newTask, http.StatusCreated)
+	})
+
+	authenticated.HandleFunc("GET /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
+			return
+		}
+		task, err := tm.GetTask(id)
+		if err != nil {
+			taskError(w, r, err)
+			return
 		}
+		jsonResponse(w, task, http.StatusOK)
 	})
 
-	http.HandleFunc("/tasks/", func(w http.ResponseWriter, r *http.Request) {
-		idStr := r.URL.Path[len("/tasks/"):]
-		id, err := strconv.Atoi(idStr)
+	authenticated.HandleFunc("PUT /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
 		if err != nil {
-			http.Error(w, "Invalid task ID", http.StatusBadRequest)
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
 			return
 		}
+		var task Task
+		if !decodeBody(w, r, &task) {
+			return
+		}
+		updatedTask, err := tm.UpdateTask(id, task.Description, task.Completed)
+		if err != nil {
+			taskError(w, r, err)
+			return
+		}
+		jsonResponse(w, updatedTask, http.StatusOK)
+	})
 
-		switch r.Method {
-		case "GET":
-			task, err := tm.GetTask(id)
-			if err != nil {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			jsonResponse(w, task, http.StatusOK)
-		case "PUT":
-			var task Task
-			if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			updatedTask, err := tm.UpdateTask(id, task.Description, task.Completed)
-			if err != nil {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			jsonResponse(w, updatedTask, http.StatusOK)
-		case "DELETE":
-			if err := tm.DeleteTask(id); err != nil {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			w.WriteHeader(http.StatusNoContent)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	authenticated.HandleFunc("DELETE /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
+			return
+		}
+		if err := tm.DeleteTask(id); err != nil {
+			taskError(w, r, err)
+			return
 		}
+		w.WriteHeader(http.StatusNoContent)
 	})
 
-	// Wrap the default mux with the logging and authentication middleware
-	loggedMux := loggingMiddleware(authenticateMiddleware(http.DefaultServeMux))
+	registerDocs(router)
 
-	fmt.Println("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", loggedMux); err != nil {
-		log.Fatalf("could not start server: %v\n", err)
-	}
+	return router
 }
 
 // jsonResponse encodes response as JSON and writes it to the ResponseWriter
@@ -353,6 +377,15 @@ func jsonResponse(w http.ResponseWriter, data interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("could not write response: %v", err)
+	}
+}
+
+// taskError replies to a failed read or write of a task
+func taskError(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, sql.ErrNoRows) {
+		httpError(w, r, http.StatusNotFound, "error.task_not_found")
+		return
 	}
+	internalError(w, r, err)
 }
@@ -0,0 +1,46 @@
+package main
+
+// serverLocale is used for clients that accept no supported language
+var serverLocale = DefaultLocale
+
+// catalogs holds the messages of every supported locale
+var catalogs = map[Locale]map[string]message{
+	English: {
+		"bool.true":                 {Other: "true"},
+		"bool.false":                {Other: "false"},
+		"error.method_not_allowed":  {Other: "Method not allowed"},
+		"error.invalid_task_id":     {Other: "Invalid task ID"},
+		"error.not_found":           {Other: "No resource at %s"},
+		"error.task_not_found":      {Other: "Task not found"},
+		"error.invalid_body":        {Other: "The request body is not valid JSON for this resource"},
+		"error.body_too_large":      {Other: "The request body is larger than %d bytes"},
+		"error.internal":            {Other: "An internal error occurred; quote the request ID when reporting it"},
+		"error.shutting_down":       {Other: "The server is shutting down; retry the request shortly"},
+		"error.missing_token":       {Other: "Missing token"},
+		"error.invalid_token":       {Other: "Invalid token"},
+		"error.invalid_credentials": {Other: "Invalid credentials"},
+		"field.boolean":             {Other: "must be true or false"},
+		"field.string":              {Other: "must be a string"},
+		"field.number":              {Other: "must be a number"},
+		"field.invalid":             {Other: "is not valid"},
+	},
+	Spanish: {
+		"bool.true":                 {Other: "sí"},
+		"bool.false":                {Other: "no"},
+		"error.method_not_allowed":  {Other: "Método no permitido"},
+		"error.invalid_task_id":     {Other: "ID de tarea no válido"},
+		"error.not_found":           {Other: "No hay ningún recurso en %s"},
+		"error.task_not_found":      {Other: "Tarea no encontrada"},
+		"error.invalid_body":        {Other: "El cuerpo de la solicitud no es un JSON válido para este recurso"},
+		"error.body_too_large":      {Other: "El cuerpo de la solicitud ocupa más de %d bytes"},
+		"error.internal":            {Other: "Se produjo un error interno; indique el ID de la solicitud al informarlo"},
+		"error.shutting_down":       {Other: "El servidor se está deteniendo; vuelva a intentarlo en breve"},
+		"error.missing_token":       {Other: "Falta el token"},
+		"error.invalid_token":       {Other: "Token no válido"},
+		"error.invalid_credentials": {Other: "Credenciales no válidas"},
+		"field.boolean":             {Other: "debe ser true o false"},
+		"field.string":              {Other: "debe ser una cadena"},
+		"field.number":              {Other: "debe ser un número"},
+		"field.invalid":             {Other: "no es válido"},
+	},
+}
@@ -0,0 +1,29 @@
+package main
+
+import (
+	"embed"
+	"net/http"
+)
+
+// openAPISpec is the OpenAPI 3.1 document of the API. It must describe
+// exactly the routes of newRouter.
+//
+//go:embed openapi.json
+var openAPISpec []byte
+
+// docsFiles holds the interactive documentation page, which reads openAPISpec
+//
+//go:embed docs
+var docsFiles embed.FS
+
+// registerDocs adds the routes that serve the OpenAPI document and the
+// documentation page
+func registerDocs(router *Router) {
+	router.HandleFunc("GET /openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openAPISpec)
+	})
+	router.HandleFunc("GET /docs", func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFileFS(w, r, docsFiles, "docs/index.html")
+	})
+}
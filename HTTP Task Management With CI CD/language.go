@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// translatorFor returns a translator for the language accepted by the client
+func translatorFor(r *http.Request) *Translator {
+	return NewTranslator(LocaleFromAcceptLanguage(r.Header.Get("Accept-Language"), serverLocale))
+}
+
+// LocaleFromAcceptLanguage returns the supported locale with the highest
+// quality in an Accept-Language header, or fallback when none is supported
+func LocaleFromAcceptLanguage(header string, fallback Locale) Locale {
+	type weighted struct {
+		locale  Locale
+		quality float64
+	}
+	var accepted []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, quality := part, 1.0
+		if i := strings.Index(part, ";"); i >= 0 {
+			tag = part[:i]
+			if q, ok := strings.CutPrefix(strings.TrimSpace(part[i+1:]), "q="); ok {
+				parsed, err := strconv.ParseFloat(q, 64)
+				if err != nil {
+					continue
+				}
+				quality = parsed
+			}
+		}
+		if locale, ok := ParseLocale(tag); ok && quality > 0 {
+			accepted = append(accepted, weighted{locale, quality})
+		}
+	}
+	if len(accepted) == 0 {
+		return fallback
+	}
+	sort.SliceStable(accepted, func(i, j int) bool { return accepted[i].quality > accepted[j].quality })
+	return accepted[0].locale
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// Adds messages to the catalogs for the length of a test
+func withMessages(t *testing.T, locale Locale, messages map[string]message) {
+	t.Helper()
+	for key, msg := range messages {
+		catalogs[locale][key] = msg
+	}
+	t.Cleanup(func() {
+		for key := range messages {
+			delete(catalogs[locale], key)
+		}
+	})
+}
+
+func TestTranslatorFallsBack(t *testing.T) {
+	withMessages(t, English, map[string]message{
+		"test.greeting": {Other: "Hello, %s"},
+		"test.only_en":  {Other: "English only"},
+		"test.items":    {One: "%d item", Other: "%d items"},
+	})
+	withMessages(t, Spanish, map[string]message{
+		"test.greeting": {Other: "Hola, %s"},
+		"test.items":    {One: "%d elemento", Other: "%d elementos"},
+	})
+
+	es := NewTranslator(Spanish)
+	if got := es.T("test.greeting", "Ana"); got != "Hola, Ana" {
+		t.Errorf("expected the Spanish message, got %q", got)
+	}
+	if got := es.T("test.only_en"); got != "English only" {
+		t.Errorf("expected a key missing in Spanish to use English, got %q", got)
+	}
+	if got := es.T("test.unknown"); got != "test.unknown" {
+		t.Errorf("expected an unknown key to be returned as is, got %q", got)
+	}
+	if got := es.N("test.items", 1, 1); got != "1 elemento" {
+		t.Errorf("expected the singular form, got %q", got)
+	}
+	if got := es.N("test.items", 0, 0); got != "0 elementos" {
+		t.Errorf("expected the plural form for zero, got %q", got)
+	}
+	if tr := NewTranslator("fr"); tr.Locale() != DefaultLocale {
+		t.Errorf("expected an unsupported locale to fall back to %s, got %s", DefaultLocale, tr.Locale())
+	}
+
+	date := time.Date(2024, 3, 5, 14, 30, 0, 0, time.UTC)
+	if got := es.Date(date); got != "5 de marzo de 2024 14:30" {
+		t.Errorf("expected a Spanish date, got %q", got)
+	}
+	if got := NewTranslator(English).Date(date); got != "March 5, 2024 2:30 PM" {
+		t.Errorf("expected an English date, got %q", got)
+	}
+}
+
+func TestSelectLocale(t *testing.T) {
+	for _, tag := range []string{"es", "ES", "es-MX", "es_ES.UTF-8", " es@euro"} {
+		if locale, ok := ParseLocale(tag); !ok || locale != Spanish {
+			t.Errorf("expected %q to be Spanish, got %s, %v", tag, locale, ok)
+		}
+	}
+	if _, ok := ParseLocale("fr_FR"); ok {
+		t.Error("expected French to be unsupported")
+	}
+
+	t.Setenv("LC_ALL", "")
+	t.Setenv("LC_MESSAGES", "fr_FR.UTF-8")
+	t.Setenv("LANG", "es_ES.UTF-8")
+	if locale := SelectLocale(""); locale != Spanish {
+		t.Errorf("expected an unsupported variable to be skipped, got %s", locale)
+	}
+	if locale := SelectLocale("en"); locale != English {
+		t.Errorf("expected the flag to win, got %s", locale)
+	}
+	t.Setenv("LANG", "C")
+	if locale := SelectLocale(""); locale != DefaultLocale {
+		t.Errorf("expected the default locale, got %s", locale)
+	}
+}
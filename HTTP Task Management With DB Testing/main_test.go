@@ -6,7 +6,6 @@ import (
 	_ "github.com/mattn/go-sqlite3"
 	"net/http"
 	"net/http/httptest"
-	"strconv"
 	"strings"
 	"testing"
 )
@@ -134,75 +133,12 @@ func TestHTTPHandlers(t *testing.T) {
 	defer db.Close()
 
 	tm := NewTaskManager(db)
-	http.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case "GET":
-			tasks, err := tm.ListTasks()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			jsonResponse(w, tasks, http.StatusOK)
-		case "POST":
-			var task Task
-			if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			newTask, err := tm.AddTask(task.Description)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			jsonResponse(w, newTask, http.StatusCreated)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-
-	http.HandleFunc("/tasks/", func(w http.ResponseWriter, r *http.Request) {
-		idStr := r.URL.Path[len("/tasks/"):]
-		id, err := strconv.Atoi(idStr)
-		if err != nil {
-			http.Error(w, "Invalid task ID", http.StatusBadRequest)
-			return
-		}
-
-		switch r.Method {
-		case "GET":
-			task, err := tm.GetTask(id)
-			if err != nil {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			jsonResponse(w, task, http.StatusOK)
-		case "PUT":
-			var task Task
-			if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			updatedTask, err := tm.UpdateTask(id, task.Description, task.Completed)
-			if err != nil {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			jsonResponse(w, updatedTask, http.StatusOK)
-		case "DELETE":
-			if err := tm.DeleteTask(id); err != nil {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			w.WriteHeader(http.StatusNoContent)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
+	router := newRouter(tm)
 
 	t.Run("Add Task", func(t *testing.T) {
 		req, _ := http.NewRequest("POST", "/tasks", strings.NewReader(`{"description":"Test Task"}`))
 		res := httptest.NewRecorder()
-		http.DefaultServeMux.ServeHTTP(res, req)
+		router.ServeHTTP(res, req)
 
 		if res.Code != http.StatusCreated {
 			t.Errorf("expected status 201 Created, got %d", res.Code)
@@ -212,7 +148,7 @@ func TestHTTPHandlers(t *testing.T) {
 	t.Run("Get Task", func(t *testing.T) {
 		req, _ := http.NewRequest("GET", "/tasks/1", nil)
 		res := httptest.NewRecorder()
-		http.DefaultServeMux.ServeHTTP(res, req)
+		router.ServeHTTP(res, req)
 
 		if res.Code != http.StatusOK {
 			t.Errorf("expected status 200 OK, got %d", res.Code)
@@ -222,7 +158,7 @@ func TestHTTPHandlers(t *testing.T) {
 	t.Run("Update Task", func(t *testing.T) {
 		req, _ := http.NewRequest("PUT", "/tasks/1", strings.NewReader(`{"description":"Updated Task","completed":true}`))
 		res := httptest.NewRecorder()
-		http.DefaultServeMux.ServeHTTP(res, req)
+		router.ServeHTTP(res, req)
 
 		if res.Code != http.StatusOK {
 			t.Errorf("expected status 200 OK, got %d", res.Code)
@@ -232,7 +168,7 @@ func TestHTTPHandlers(t *testing.T) {
 	t.Run("Delete Task", func(t *testing.T) {
 		req, _ := http.NewRequest("DELETE", "/tasks/1", nil)
 		res := httptest.NewRecorder()
-		http.DefaultServeMux.ServeHTTP(res, req)
+		router.ServeHTTP(res, req)
 
 		if res.Code != http.StatusNoContent {
 			t.Errorf("expected status 204 No Content, got %d", res.Code)
@@ -242,10 +178,68 @@ func TestHTTPHandlers(t *testing.T) {
 	t.Run("List Tasks", func(t *testing.T) {
 		req, _ := http.NewRequest("GET", "/tasks", nil)
 		res := httptest.NewRecorder()
-		http.DefaultServeMux.ServeHTTP(res, req)
+		router.ServeHTTP(res, req)
 
 		if res.Code != http.StatusOK {
 			t.Errorf("expected status 200 OK, got %d", res.Code)
 		}
 	})
+
+	t.Run("Missing Task", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/tasks/1", nil)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 Not Found, got %d", res.Code)
+		}
+		var problem Problem
+		if err := json.NewDecoder(res.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+		if res.Header().Get("Content-Type") != problemContentType || problem.Type != "/problems/task-not-found" || problem.Instance != "/tasks/1" {
+			t.Errorf("expected a task-not-found problem, got %+v", problem)
+		}
+	})
+
+	t.Run("Invalid Body", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/tasks", strings.NewReader(`{"description":true}`))
+		req.Header.Set("Accept-Language", "es")
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		var problem Problem
+		if err := json.NewDecoder(res.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+		if res.Code != http.StatusBadRequest || len(problem.Errors) != 1 || problem.Errors[0].Field != "description" {
+			t.Errorf("expected a 400 naming the description field, got %d %+v", res.Code, problem)
+		}
+		if res.Header().Get("Content-Language") != "es" || problem.Errors[0].Message != "debe ser una cadena" {
+			t.Errorf("expected the problem in Spanish, got %+v", problem)
+		}
+	})
+
+	t.Run("Unknown Path", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/tasks/1/extra", nil)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		if res.Code != http.StatusNotFound {
+			t.Errorf("expected status 404 Not Found, got %d", res.Code)
+		}
+	})
+
+	t.Run("Method Not Allowed", func(t *testing.T) {
+		req, _ := http.NewRequest("PATCH", "/tasks/1", nil)
+		res := httptest.NewRecorder()
+		router.ServeHTTP(res, req)
+
+		if res.Code != http.StatusMethodNotAllowed {
+			t.Errorf("expected status 405 Method Not Allowed, got %d", res.Code)
+		}
+		if allow := res.Header().Get("Allow"); allow != "DELETE, GET, HEAD, OPTIONS, PUT" {
+			t.Errorf("expected the Allow header to list the methods of the path, got %q", allow)
+		}
+	})
 }
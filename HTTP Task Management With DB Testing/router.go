@@ -0,0 +1,171 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Middleware wraps a handler with extra behavior
+type Middleware func(http.Handler) http.Handler
+
+// Router dispatches requests by method and path. Patterns look like
+// "GET /tasks/{id}"; the value of each {name} segment is available to the
+// handler through r.PathValue and PathInt.
+type Router struct {
+	routes []route
+}
+
+// RouteGroup registers routes on a router behind a set of middleware
+type RouteGroup struct {
+	router     *Router
+	middleware []Middleware
+}
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.Handler
+}
+
+// NewRouter creates a new Router
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers the handler for a pattern such as "GET /tasks/{id}"
+func (rt *Router) Handle(pattern string, handler http.Handler) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok || method == "" || !strings.HasPrefix(path, "/") {
+		panic("router: invalid pattern " + strconv.Quote(pattern))
+	}
+	rt.routes = append(rt.routes, route{method: method, segments: splitPath(path), handler: handler})
+}
+
+// HandleFunc registers the handler function for a pattern
+func (rt *Router) HandleFunc(pattern string, handler http.HandlerFunc) {
+	rt.Handle(pattern, handler)
+}
+
+// Group returns a route group whose routes run behind the given middleware,
+// the first one outermost
+func (rt *Router) Group(middleware ...Middleware) *RouteGroup {
+	return &RouteGroup{router: rt, middleware: middleware}
+}
+
+// Handle registers the handler for a pattern behind the middleware of the group
+func (g *RouteGroup) Handle(pattern string, handler http.Handler) {
+	for i := len(g.middleware) - 1; i >= 0; i-- {
+		handler = g.middleware[i](handler)
+	}
+	g.router.Handle(pattern, handler)
+}
+
+// HandleFunc registers the handler function for a pattern behind the
+// middleware of the group
+func (g *RouteGroup) HandleFunc(pattern string, handler http.HandlerFunc) {
+	g.Handle(pattern, handler)
+}
+
+// Routes returns the patterns of the registered routes in the order they
+// were registered
+func (rt *Router) Routes() []string {
+	patterns := make([]string, len(rt.routes))
+	for i, route := range rt.routes {
+		patterns[i] = route.method + " /" + strings.Join(route.segments, "/")
+	}
+	return patterns
+}
+
+// ServeHTTP dispatches the request to the matching route, preferring the
+// route with the most literal segments. A path that matches no route gets a
+// 404. A path that matches routes for other methods gets a 405 with an Allow
+// header, and OPTIONS requests are answered with the Allow header unless a
+// route handles them. HEAD requests are served by GET routes.
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	segments := splitPath(r.URL.Path)
+	allowed := make(map[string]bool)
+	var best *route
+	var bestParams map[string]string
+	for i := range rt.routes {
+		route := &rt.routes[i]
+		params, ok := route.match(segments)
+		if !ok {
+			continue
+		}
+		allowed[route.method] = true
+		if route.method != r.Method && (route.method != http.MethodGet || r.Method != http.MethodHead) {
+			continue
+		}
+		if best == nil || len(params) < len(bestParams) {
+			best, bestParams = route, params
+		}
+	}
+	if best != nil {
+		for name, value := range bestParams {
+			r.SetPathValue(name, value)
+		}
+		best.handler.ServeHTTP(w, r)
+		return
+	}
+
+	if len(allowed) == 0 {
+		httpError(w, r, http.StatusNotFound, "error.not_found", r.URL.Path)
+		return
+	}
+	if allowed[http.MethodGet] {
+		allowed[http.MethodHead] = true
+	}
+	allowed[http.MethodOptions] = true
+	methods := make([]string, 0, len(allowed))
+	for method := range allowed {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+
+	if r.Method == http.MethodOptions {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	httpError(w, r, http.StatusMethodNotAllowed, "error.method_not_allowed")
+}
+
+// Matches the segments of a path, returning the values of the parameters
+func (rt *route) match(segments []string) (map[string]string, bool) {
+	if len(segments) != len(rt.segments) {
+		return nil, false
+	}
+	var params map[string]string
+	for i, segment := range rt.segments {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			if segments[i] == "" {
+				return nil, false
+			}
+			if params == nil {
+				params = make(map[string]string)
+			}
+			params[segment[1:len(segment)-1]] = segments[i]
+			continue
+		}
+		if segment != segments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// Splits a path into its segments, ignoring a trailing slash
+func splitPath(path string) []string {
+	path = strings.TrimSuffix(strings.TrimPrefix(path, "/"), "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// PathInt returns the path parameter with the given name as an int
+func PathInt(r *http.Request, name string) (int, error) {
+	return strconv.Atoi(r.PathValue(name))
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Modes of a batch
+const (
+	BatchAtomic     = "atomic"
+	BatchBestEffort = "best_effort"
+)
+
+// Operations of a batch
+const (
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchDelete = "delete"
+)
+
+// DefaultMaxBatchSize is the number of operations a batch may have unless
+// the server is configured otherwise
+const DefaultMaxBatchSize = 1000
+
+// batchOperationBytes is the room a batch body has for each operation it may
+// have. A batch body may be larger than MaxBodySize when the server allows
+// more than 1024 operations.
+const batchOperationBytes = 1 << 10
+
+// errBatchTooLarge is returned by decodeBatch for a batch with more
+// operations than the server allows
+var errBatchTooLarge = errors.New("batch has too many operations")
+
+// errMalformedBatch is returned by decodeBatch for a body that is not a JSON
+// object with an operations array
+var errMalformedBatch = errors.New("malformed batch")
+
+// BatchRequest is the body of POST /tasks/batch. In atomic mode, the default,
+// either every operation is written or none is. In best_effort mode the
+// operations that succeed are written and the others are reported.
+type BatchRequest struct {
+	Mode       string           `json:"mode"`
+	Operations []BatchOperation `json:"operations"`
+}
+
+// BatchOperation creates a task, or updates or deletes the task with ID.
+// Version, when set, is the version the task must have, like an If-Match
+// header on a single write.
+type BatchOperation struct {
+	Op      string `json:"op"`
+	ID      int    `json:"id,omitempty"`
+	Version int    `json:"version,omitempty"`
+	Task    *Task  `json:"task,omitempty"`
+}
+
+// BatchOutcome is what one operation of a batch did: the task it wrote, nil
+// for a delete, or the error that stopped it
+type BatchOutcome struct {
+	Task *Task
+	Err  error
+}
+
+// BatchResult reports one operation of a batch with the status and body the
+// single request would have had
+type BatchResult struct {
+	Index  int      `json:"index"`
+	Op     string   `json:"op"`
+	Status int      `json:"status"`
+	Task   *Task    `json:"task,omitempty"`
+	Error  *Problem `json:"error,omitempty"`
+}
+
+// BatchResponse is the reply to a batch
+type BatchResponse struct {
+	Mode      string        `json:"mode"`
+	Committed bool          `json:"committed"`
+	Results   []BatchResult `json:"results"`
+}
+
+// RunBatch runs the operations of a batch in one transaction. In atomic mode
+// it stops at the first operation that fails and rolls back, so the outcomes
+// end with that operation and committed is false. The events of the writes
+// are published once they are committed. err is a failure of the
+// transaction itself.
+func (tm *TaskManager) RunBatch(mode string, operations []BatchOperation) (outcomes []BatchOutcome, committed bool, err error) {
+	tx, err := tm.db.Begin()
+	if err != nil {
+		return nil, false, err
+	}
+	// Does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	// Each operation writes with a single statement, so one that fails
+	// leaves the transaction as it was
+	for _, operation := range operations {
+		task, err := tm.runOperation(tx, operation)
+		outcomes = append(outcomes, BatchOutcome{Task: task, Err: err})
+		if err != nil && mode == BatchAtomic {
+			return outcomes, false, nil
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, false, err
+	}
+
+	for i, operation := range operations {
+		outcome := outcomes[i]
+		switch {
+		case outcome.Err != nil:
+		case operation.Op == batchCreate:
+			tm.events.Publish(TaskCreated, outcome.Task.ID, outcome.Task)
+		case operation.Op == batchUpdate:
+			tm.events.Publish(TaskUpdated, operation.ID, outcome.Task)
+		case operation.Op == batchDelete:
+			tm.events.Publish(TaskDeleted, operation.ID, nil)
+		}
+	}
+	return outcomes, true, nil
+}
+
+// Runs one operation of a batch in its transaction
+func (tm *TaskManager) runOperation(tx *sql.Tx, operation BatchOperation) (*Task, error) {
+	if err := validateOperation(operation); err != nil {
+		return nil, err
+	}
+	precondition := Precondition{}
+	if operation.Version != 0 {
+		precondition = IfVersion(operation.Version)
+	}
+
+	switch operation.Op {
+	case batchCreate:
+		return insertTask(tx, operation.Task.Description)
+	case batchUpdate:
+		return tm.updateTask(tx, operation.ID, operation.Task.Description, operation.Task.Completed, precondition)
+	default:
+		return nil, tm.deleteTask(tx, operation.ID, precondition)
+	}
+}
+
+// Reads a batch from body one operation at a time, and stops with
+// errBatchTooLarge at the first operation past max, so an oversized batch is
+// never held in memory. Unknown fields are skipped, as decodeBody does.
+func decodeBatch(body io.Reader, max int) (BatchRequest, error) {
+	var batch BatchRequest
+	decoder := json.NewDecoder(body)
+	if err := expectDelim(decoder, '{'); err != nil {
+		return batch, err
+	}
+	for decoder.More() {
+		name, err := decoder.Token()
+		if err != nil {
+			return batch, err
+		}
+		switch name {
+		case "mode":
+			err = decodeField(decoder, "mode", &batch.Mode)
+		case "operations":
+			batch.Operations, err = decodeOperations(decoder, max)
+		default:
+			var skipped json.RawMessage
+			err = decoder.Decode(&skipped)
+		}
+		if err != nil {
+			return batch, err
+		}
+	}
+	return batch, expectDelim(decoder, '}')
+}
+
+// Reads the operations array of a batch, or null, up to max operations
+func decodeOperations(decoder *json.Decoder, max int) ([]BatchOperation, error) {
+	token, err := decoder.Token()
+	if err != nil || token == nil {
+		return nil, err
+	}
+	if token != json.Delim('[') {
+		return nil, &fieldError{field: "operations", key: "field.invalid"}
+	}
+	var operations []BatchOperation
+	for decoder.More() {
+		if len(operations) == max {
+			return nil, errBatchTooLarge
+		}
+		var operation BatchOperation
+		if err := decodeField(decoder, "operations", &operation); err != nil {
+			return nil, err
+		}
+		operations = append(operations, operation)
+	}
+	return operations, expectDelim(decoder, ']')
+}
+
+// Decodes the next value into v, naming the field of a batch it belongs to
+// in type errors
+func decodeField(decoder *json.Decoder, field string, v interface{}) error {
+	err := decoder.Decode(v)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		typeErr.Field = strings.TrimSuffix(field+"."+typeErr.Field, ".")
+	}
+	return err
+}
+
+// Reads the next token and checks that it is delim
+func expectDelim(decoder *json.Decoder, delim json.Delim) error {
+	token, err := decoder.Token()
+	if err == nil && token != delim {
+		err = errMalformedBatch
+	}
+	return err
+}
+
+// Checks that an operation of a batch has the fields its op needs
+func validateOperation(operation BatchOperation) error {
+	switch operation.Op {
+	case batchCreate, batchUpdate, batchDelete:
+	default:
+		return &fieldError{field: "op", key: "field.operation"}
+	}
+	if operation.Op != batchCreate && operation.ID <= 0 {
+		return &fieldError{field: "id", key: "field.required"}
+	}
+	if operation.Op == batchDelete {
+		return nil
+	}
+	if operation.Task == nil {
+		return &fieldError{field: "task", key: "field.required"}
+	}
+	if err := validateTask(operation.Task); err != nil {
+		var invalid *fieldError
+		if errors.As(err, &invalid) {
+			return &fieldError{field: "task." + invalid.field, key: invalid.key, args: invalid.args}
+		}
+		return err
+	}
+	return nil
+}
+
+// taskBatchHandler runs a batch of task operations. The reply has a result
+// for every operation. It is 200 OK unless an atomic batch was rolled back;
+// then it has the status of the operation that failed.
+func taskBatchHandler(tm *TaskManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit := max(MaxBodySize, int64(tm.MaxBatchSize)*batchOperationBytes)
+		batch, err := decodeBatch(http.MaxBytesReader(w, r.Body, limit), tm.MaxBatchSize)
+		var invalid *fieldError
+		switch {
+		case errors.Is(err, errBatchTooLarge):
+			httpError(w, r, http.StatusRequestEntityTooLarge, "error.batch_too_large", tm.MaxBatchSize)
+			return
+		case errors.As(err, &invalid):
+			validationError(w, r, http.StatusBadRequest, "error.invalid_body", invalid)
+			return
+		case err != nil:
+			decodeError(w, r, err)
+			return
+		}
+		if batch.Mode == "" {
+			batch.Mode = BatchAtomic
+		}
+		if batch.Mode != BatchAtomic && batch.Mode != BatchBestEffort {
+			validationError(w, r, http.StatusUnprocessableEntity, "error.invalid_batch", &fieldError{field: "mode", key: "field.batch_mode"})
+			return
+		}
+		if len(batch.Operations) == 0 {
+			validationError(w, r, http.StatusUnprocessableEntity, "error.invalid_batch", &fieldError{field: "operations", key: "field.required"})
+			return
+		}
+
+		outcomes, committed, err := tm.RunBatch(batch.Mode, batch.Operations)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+
+		tr := translatorFor(r)
+		response := BatchResponse{Mode: batch.Mode, Committed: committed}
+		status := http.StatusOK
+		for i, operation := range batch.Operations {
+			result := BatchResult{Index: i, Op: operation.Op}
+			switch {
+			case i < len(outcomes) && outcomes[i].Err != nil:
+				var key string
+				result.Status, key = batchError(r, operation, outcomes[i].Err)
+				problem := newProblem(r, tr, result.Status, key, nil, fieldErrors(outcomes[i].Err))
+				result.Error = &problem
+				if !committed {
+					status = result.Status
+				}
+			case !committed:
+				// Written and rolled back, or never run
+				result.Status = http.StatusFailedDependency
+				problem := newProblem(r, tr, result.Status, "error.batch_rolled_back", []interface{}{len(outcomes) - 1}, nil)
+				result.Error = &problem
+			case operation.Op == batchCreate:
+				result.Status, result.Task = http.StatusCreated, outcomes[i].Task
+			case operation.Op == batchUpdate:
+				result.Status, result.Task = http.StatusOK, outcomes[i].Task
+			default:
+				result.Status = http.StatusNoContent
+			}
+			response.Results = append(response.Results, result)
+		}
+		jsonResponse(w, response, status)
+	}
+}
+
+// Returns the status and message key a single request would have had for
+// the error of an operation, logging unexpected errors
+func batchError(r *http.Request, operation BatchOperation, err error) (int, string) {
+	var invalid *fieldError
+	switch {
+	case errors.As(err, &invalid):
+		return http.StatusUnprocessableEntity, "error.invalid_operation"
+	case errors.Is(err, ErrTaskNotFound):
+		return http.StatusNotFound, "error.task_not_found"
+	case errors.Is(err, ErrVersionMismatch):
+		return http.StatusPreconditionFailed, "error.version_mismatch"
+	case errors.Is(err, ErrPreconditionRequired):
+		return http.StatusPreconditionRequired, "error.version_required"
+	}
+	log.Printf("request %s: batch %s of task %d: %v", requestID(r), operation.Op, operation.ID, err)
+	return http.StatusInternalServerError, "error.internal"
+}
+
+// Returns the field error in err as a list, or nil when there is none
+func fieldErrors(err error) []*fieldError {
+	var invalid *fieldError
+	if errors.As(err, &invalid) {
+		return []*fieldError{invalid}
+	}
+	return nil
+}
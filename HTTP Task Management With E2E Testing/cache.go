@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revision marks a state of the task collection. Number goes up with every
+// write to any task.
+type Revision struct {
+	Number    int
+	UpdatedAt time.Time
+}
+
+// ETag returns the strong ETag of the task list at this revision. Every
+// query of the list has its own URL, so one ETag serves them all. Like task
+// ETags it starts with etagPrefix.
+func (rev Revision) ETag() string {
+	return `"tasks-` + etagPrefix + strconv.Itoa(rev.Number) + `"`
+}
+
+// notModified sets the validators of a response and returns true, after
+// replying with a 304, when the client's copy is still current. If-None-Match
+// takes precedence over If-Modified-Since, as in RFC 9110.
+func notModified(w http.ResponseWriter, r *http.Request, etag string, modified time.Time) bool {
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("ETag", etag)
+	if !modified.IsZero() {
+		w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	current := false
+	if values := r.Header.Values("If-None-Match"); len(values) > 0 {
+		current = noneMatchIncludes(values, etag)
+	} else if since, err := http.ParseTime(r.Header.Get("If-Modified-Since")); err == nil && !modified.IsZero() {
+		current = !modified.Truncate(time.Second).After(since)
+	}
+	if current {
+		w.WriteHeader(http.StatusNotModified)
+	}
+	return current
+}
+
+// Returns true if an If-None-Match header lists an ETag, using the weak
+// comparison
+func noneMatchIncludes(values []string, etag string) bool {
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+	}
+	return false
+}
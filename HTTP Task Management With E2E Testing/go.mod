@@ -3,6 +3,8 @@ module HTTP_Task_Management_With_E2E_Testing
 go 1.22
 
 require (
+	github.com/dgrijalva/jwt-go v3.2.0+incompatible
+	github.com/gorilla/websocket v1.5.3
 	github.com/mattn/go-sqlite3 v1.14.22
 	golang.org/x/crypto v0.25.0
 )
@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestLocaleFromAcceptLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   Locale
+	}{
+		{"", English},
+		{"es", Spanish},
+		{"es-ES,es;q=0.9", Spanish},
+		{"en;q=0.5, es;q=0.8", Spanish},
+		{"fr-FR, es;q=0.1", Spanish},
+		{"es;q=0, en;q=0.2", English},
+		{"es;q=abc, en;q=0.2", English},
+		{"fr, de;q=0.9", English},
+		{"en, es", English},
+		{"ES-mx ; q=1", Spanish},
+	}
+	for _, test := range tests {
+		if got := LocaleFromAcceptLanguage(test.header, English); got != test.want {
+			t.Errorf("%q: expected %s, got %s", test.header, test.want, got)
+		}
+	}
+	if got := LocaleFromAcceptLanguage("fr", Spanish); got != Spanish {
+		t.Errorf("expected the fallback when nothing is supported, got %s", got)
+	}
+}
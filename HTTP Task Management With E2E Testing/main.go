@@ -1,15 +1,21 @@
 package main
 
 import (
+	"bytes"
 	"database/sql"
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
-	"strconv"
+	"slices"
+	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/mattn/go-sqlite3"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -31,79 +37,335 @@ type Task struct {
 	Description string    `json:"description"`
 	Completed   bool      `json:"completed"`
 	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int       `json:"version"`
 }
 
-// TaskManager struct
+// ErrTaskNotFound is returned when a read or write targets a task that does
+// not exist. It is sql.ErrNoRows, which the queries return for a missing row.
+var ErrTaskNotFound = sql.ErrNoRows
+
+// etagPrefix starts the ETags of tasks and of the task list. Versions and
+// the revision are kept in the database and survive a restart, so they need
+// no token of the run.
+const etagPrefix = ""
+
+// TaskManager struct. With RequirePreconditions set, writes to existing tasks
+// fail unless they carry a precondition. MaxBatchSize limits the operations
+// of a batch.
 type TaskManager struct {
-	db *sql.DB
+	db                   *sql.DB
+	events               *EventBroker
+	RequirePreconditions bool
+	MaxBatchSize         int
+}
+
+// The methods of *sql.DB and *sql.Tx the task writes use, so that a batch
+// runs them in a transaction
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
 }
 
 // NewTaskManager creates a new TaskManager
 func NewTaskManager(db *sql.DB) *TaskManager {
-	return &TaskManager{db: db}
+	return &TaskManager{db: db, events: NewEventBroker(), MaxBatchSize: DefaultMaxBatchSize}
+}
+
+// Events returns the broker of the events of the writes made through this
+// TaskManager
+func (tm *TaskManager) Events() *EventBroker {
+	return tm.events
+}
+
+// The columns of a task, in the order scanTask reads them
+const taskColumns = `id, description, completed, created_at, updated_at, version`
+
+// Columns added to the tasks table after it was first created, with the
+// statement that fills them in for existing rows
+var taskColumnMigrations = []struct{ name, definition, backfill string }{
+	{"version", "INTEGER NOT NULL DEFAULT 1", ""},
+	{"updated_at", "DATETIME", "UPDATE tasks SET updated_at = created_at"},
 }
 
-// InitializeDB initializes the database with the tasks table
+// InitializeDB initializes the database with the tasks and users tables and
+// the revision of the task collection
 func (tm *TaskManager) InitializeDB() error {
-	query := `
+	tasksQuery := `
     CREATE TABLE IF NOT EXISTS tasks (
         id INTEGER PRIMARY KEY AUTOINCREMENT,
         description TEXT,
         completed BOOLEAN,
-        created_at DATETIME
+        created_at DATETIME,
+        version INTEGER NOT NULL DEFAULT 1,
+        updated_at DATETIME
+    );
+    `
+	// Triggers move the revision forward on every write to the tasks table,
+	// so reading it is enough to tell whether the list changed
+	revisionQuery := `
+    CREATE TABLE IF NOT EXISTS task_revision (
+        id INTEGER PRIMARY KEY CHECK (id = 1),
+        number INTEGER NOT NULL,
+        updated_at DATETIME NOT NULL
+    );
+    INSERT OR IGNORE INTO task_revision (id, number, updated_at) VALUES (1, 0, CURRENT_TIMESTAMP);
+    CREATE TRIGGER IF NOT EXISTS tasks_insert_revision AFTER INSERT ON tasks BEGIN
+        UPDATE task_revision SET number = number + 1, updated_at = CURRENT_TIMESTAMP;
+    END;
+    CREATE TRIGGER IF NOT EXISTS tasks_update_revision AFTER UPDATE ON tasks BEGIN
+        UPDATE task_revision SET number = number + 1, updated_at = CURRENT_TIMESTAMP;
+    END;
+    CREATE TRIGGER IF NOT EXISTS tasks_delete_revision AFTER DELETE ON tasks BEGIN
+        UPDATE task_revision SET number = number + 1, updated_at = CURRENT_TIMESTAMP;
+    END;
+    `
+	usersQuery := `
+    CREATE TABLE IF NOT EXISTS users (
+        id INTEGER PRIMARY KEY AUTOINCREMENT,
+        username TEXT UNIQUE,
+        password TEXT
     );
     `
-	_, err := tm.db.Exec(query)
+	_, err := tm.db.Exec(tasksQuery)
+	if err != nil {
+		return err
+	}
+	if err := tm.addMissingColumns(); err != nil {
+		return err
+	}
+	if _, err := tm.db.Exec(revisionQuery); err != nil {
+		return err
+	}
+	_, err = tm.db.Exec(usersQuery)
 	return err
 }
 
+// Adds the columns a tasks table created by an older version lacks
+func (tm *TaskManager) addMissingColumns() error {
+	for _, column := range taskColumnMigrations {
+		var count int
+		query := `SELECT COUNT(*) FROM pragma_table_info('tasks') WHERE name = ?`
+		if err := tm.db.QueryRow(query, column.name).Scan(&count); err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+		if _, err := tm.db.Exec(`ALTER TABLE tasks ADD COLUMN ` + column.name + ` ` + column.definition); err != nil {
+			return err
+		}
+		if column.backfill != "" {
+			if _, err := tm.db.Exec(column.backfill); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// openDatabase opens the SQLite database at path. Its transactions take the
+// write lock when they begin instead of at their first write, so one that
+// reads before it writes waits for other writers rather than failing with
+// SQLITE_BUSY.
+func openDatabase(path string) (*sql.DB, error) {
+	return sql.Open("sqlite3", path+"?_txlock=immediate")
+}
+
 // AddTask adds a new task
 func (tm *TaskManager) AddTask(description string) (*Task, error) {
-	query := `INSERT INTO tasks (description, completed, created_at) VALUES (?, ?, ?)`
-	result, err := tm.db.Exec(query, description, false, time.Now())
-	if err != nil {
-		return nil, err
-	}
-	id, err := result.LastInsertId()
+	task, err := insertTask(tm.db, description)
 	if err != nil {
 		return nil, err
 	}
-	return tm.GetTask(int(id))
+	return tm.publishTask(TaskCreated, task), nil
+}
+
+// Inserts a task with q and returns the row it wrote
+func insertTask(q queryer, description string) (*Task, error) {
+	now := time.Now()
+	query := `INSERT INTO tasks (description, completed, created_at, updated_at) VALUES (?, ?, ?, ?) RETURNING ` + taskColumns
+	return scanTask(q.QueryRow(query, description, false, now, now))
 }
 
 // GetTask gets a task by ID
 func (tm *TaskManager) GetTask(id int) (*Task, error) {
-	query := `SELECT id, description, completed, created_at FROM tasks WHERE id = ?`
-	row := tm.db.QueryRow(query, id)
+	return getTask(tm.db, id)
+}
 
+// Reads a task by ID with q
+func getTask(q queryer, id int) (*Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id = ?`
+	return scanTask(q.QueryRow(query, id))
+}
+
+// Revision returns the current revision of the task collection
+func (tm *TaskManager) Revision() (Revision, error) {
+	var revision Revision
+	query := `SELECT number, updated_at FROM task_revision WHERE id = 1`
+	err := tm.db.QueryRow(query).Scan(&revision.Number, &revision.UpdatedAt)
+	return revision, err
+}
+
+// Reads a task from a row with the taskColumns
+func scanTask(row interface{ Scan(...interface{}) error }) (*Task, error) {
 	var task Task
-	err := row.Scan(&task.ID, &task.Description, &task.Completed, &task.CreatedAt)
+	err := row.Scan(&task.ID, &task.Description, &task.Completed, &task.CreatedAt, &task.UpdatedAt, &task.Version)
 	if err != nil {
 		return nil, err
 	}
 	return &task, nil
 }
 
-// UpdateTask updates a task by ID
-func (tm *TaskManager) UpdateTask(id int, description string, completed bool) (*Task, error) {
-	query := `UPDATE tasks SET description = ?, completed = ? WHERE id = ?`
-	_, err := tm.db.Exec(query, description, completed, id)
+// UpdateTask updates a task by ID if the precondition allows its version
+func (tm *TaskManager) UpdateTask(id int, description string, completed bool, precondition Precondition) (*Task, error) {
+	task, err := tm.updateTask(tm.db, id, description, completed, precondition)
+	if err != nil {
+		return nil, err
+	}
+	return tm.publishTask(TaskUpdated, task), nil
+}
+
+// Writes a task by ID with q and returns the row it wrote; the caller
+// publishes the event
+func (tm *TaskManager) updateTask(q queryer, id int, description string, completed bool, precondition Precondition) (*Task, error) {
+	query := `UPDATE tasks SET description = ?, completed = ?, updated_at = ?, version = version + 1 WHERE id = ?`
+	return tm.execWrite(q, query, []interface{}{description, completed, time.Now(), id}, id, precondition)
+}
+
+// ApplyPatch applies a merge patch or JSON Patch of the given content type to
+// a task by ID if the precondition allows its version. The version only
+// changes when a field does. The task is read, patched and written in one
+// transaction. The server opens the database so that transactions take the
+// write lock when they begin, so no other write can come between reading the
+// task and writing it. Errors of the patch itself are *invalidPatchError.
+func (tm *TaskManager) ApplyPatch(id int, contentType string, patch []byte, precondition Precondition) (*Task, error) {
+	tx, err := tm.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	// Does nothing once the transaction is committed
+	defer tx.Rollback()
+
+	task, err := getTask(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := precondition.Check(task.Version, tm.RequirePreconditions); err != nil {
+		return nil, err
+	}
+	changes, err := patchTask(task, contentType, bytes.NewReader(patch))
 	if err != nil {
+		return nil, &invalidPatchError{err}
+	}
+	if changes.Empty() {
+		return task, nil
+	}
+	// Nothing else writes until the commit, so the version still matches
+	if task, err = tm.writeChanges(tx, id, changes, IfVersion(task.Version)); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
-	return tm.GetTask(id)
+	return tm.publishTask(TaskUpdated, task), nil
+}
+
+// Writes the changed fields of a task by ID with q and returns the row it
+// wrote; changes must not be empty and the caller publishes the event
+func (tm *TaskManager) writeChanges(q queryer, id int, changes TaskChanges, precondition Precondition) (*Task, error) {
+	var columns []string
+	var args []interface{}
+	if changes.Description != nil {
+		columns = append(columns, "description = ?")
+		args = append(args, *changes.Description)
+	}
+	if changes.Completed != nil {
+		columns = append(columns, "completed = ?")
+		args = append(args, *changes.Completed)
+	}
+	columns = append(columns, "updated_at = ?", "version = version + 1")
+	args = append(args, time.Now())
+	query := `UPDATE tasks SET ` + strings.Join(columns, ", ") + ` WHERE id = ?`
+	return tm.execWrite(q, query, append(args, id), id, precondition)
+}
+
+// DeleteTask deletes a task by ID if the precondition allows its version; it
+// returns sql.ErrNoRows when the task does not exist
+func (tm *TaskManager) DeleteTask(id int, precondition Precondition) error {
+	if err := tm.deleteTask(tm.db, id, precondition); err != nil {
+		return err
+	}
+	tm.events.Publish(TaskDeleted, id, nil)
+	return nil
 }
 
-// DeleteTask deletes a task by ID
-func (tm *TaskManager) DeleteTask(id int) error {
+// Deletes a task by ID with q; the caller publishes the event
+func (tm *TaskManager) deleteTask(q queryer, id int, precondition Precondition) error {
 	query := `DELETE FROM tasks WHERE id = ?`
-	_, err := tm.db.Exec(query, id)
+	_, err := tm.execWrite(q, query, []interface{}{id}, id, precondition)
 	return err
 }
 
+// Applies a create, update, patch or delete message of the task WebSocket and
+// returns the task it wrote, or nil for a delete
+func (tm *TaskManager) applyMutation(message wsMessage) (*Task, error) {
+	switch message.Type {
+	case wsCreate:
+		task, err := messageTask(message)
+		if err != nil {
+			return nil, err
+		}
+		return tm.AddTask(task.Description)
+	case wsUpdate:
+		task, err := messageTask(message)
+		if err != nil {
+			return nil, err
+		}
+		return tm.UpdateTask(message.TaskID, task.Description, task.Completed, messagePrecondition(message))
+	case wsPatch:
+		return tm.ApplyPatch(message.TaskID, messagePatchType(message), message.Patch, messagePrecondition(message))
+	case wsDelete:
+		return nil, tm.DeleteTask(message.TaskID, messagePrecondition(message))
+	}
+	return nil, errUnknownMessage
+}
+
+// Publishes the event of a write with the row the write returned, so a later
+// write cannot change what the event says
+func (tm *TaskManager) publishTask(eventType string, task *Task) *Task {
+	tm.events.Publish(eventType, task.ID, task)
+	return task
+}
+
+// Runs an UPDATE or DELETE of one task, whose query ends with its WHERE
+// clause, only if the precondition allows the version of the task, and
+// returns the row as the statement left it. When no row changes it returns
+// sql.ErrNoRows for a missing task and ErrVersionMismatch otherwise.
+func (tm *TaskManager) execWrite(q queryer, query string, args []interface{}, id int, precondition Precondition) (*Task, error) {
+	if !precondition.present && tm.RequirePreconditions {
+		return nil, ErrPreconditionRequired
+	}
+	if precondition.present && !precondition.any {
+		// NULL keeps the list valid SQL when no ETag could be parsed
+		query += ` AND version IN (NULL` + strings.Repeat(", ?", len(precondition.versions)) + `)`
+		for _, version := range precondition.versions {
+			args = append(args, version)
+		}
+	}
+
+	task, err := scanTask(q.QueryRow(query+` RETURNING `+taskColumns, args...))
+	if err != sql.ErrNoRows {
+		return task, err
+	}
+	if _, err := getTask(q, id); err != nil {
+		return nil, err
+	}
+	return nil, ErrVersionMismatch
+}
+
 // ListTasks lists all tasks
 func (tm *TaskManager) ListTasks() ([]*Task, error) {
-	query := `SELECT id, description, completed, created_at FROM tasks`
+	query := `SELECT ` + taskColumns + ` FROM tasks`
 	rows, err := tm.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -112,19 +374,116 @@ func (tm *TaskManager) ListTasks() ([]*Task, error) {
 
 	var tasks []*Task
 	for rows.Next() {
-		var task Task
-		if err := rows.Scan(&task.ID, &task.Description, &task.Completed, &task.CreatedAt); err != nil {
+		task, err := scanTask(rows)
+		if err != nil {
 			return nil, err
 		}
-		tasks = append(tasks, &task)
+		tasks = append(tasks, task)
 	}
 	return tasks, nil
 }
 
+// QueryTasks returns the page of tasks selected by a query. Filtering,
+// ordering and seeking to the cursor are done in SQL.
+func (tm *TaskManager) QueryTasks(q TaskQuery) (TaskPage, error) {
+	if !taskSortFields[q.Sort] {
+		return TaskPage{}, fmt.Errorf("cannot sort by %q", q.Sort)
+	}
+
+	var conditions []string
+	var args []interface{}
+	if q.Completed != nil {
+		conditions = append(conditions, "completed = ?")
+		args = append(args, *q.Completed)
+	}
+
+	var total int
+	countQuery := `SELECT COUNT(*) FROM tasks` + whereClause(conditions)
+	if err := tm.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
+		return TaskPage{}, err
+	}
+
+	// A page before the cursor is read in reverse order and flipped afterwards
+	backward := q.Cursor != nil && q.Cursor.Before
+	descending := q.Descending != backward
+	comparison, direction := ">", "ASC"
+	if descending {
+		comparison, direction = "<", "DESC"
+	}
+	if q.Cursor != nil {
+		key, err := q.Cursor.key(q.Sort)
+		if err != nil {
+			return TaskPage{}, err
+		}
+		conditions = append(conditions, fmt.Sprintf("(%[1]s %[2]s ? OR (%[1]s = ? AND id %[2]s ?))", q.Sort, comparison))
+		args = append(args, key, key, q.Cursor.ID)
+	}
+
+	query := fmt.Sprintf(`SELECT %s FROM tasks%s ORDER BY %s %s, id %s LIMIT ?`,
+		taskColumns, whereClause(conditions), q.Sort, direction, direction)
+	rows, err := tm.db.Query(query, append(args, q.Limit+1)...)
+	if err != nil {
+		return TaskPage{}, err
+	}
+	defer rows.Close()
+
+	var tasks []*Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return TaskPage{}, err
+		}
+		tasks = append(tasks, task)
+	}
+	if err := rows.Err(); err != nil {
+		return TaskPage{}, err
+	}
+
+	more := len(tasks) > q.Limit
+	if more {
+		tasks = tasks[:q.Limit]
+	}
+	if backward {
+		slices.Reverse(tasks)
+	}
+	return q.page(tasks, more, total), nil
+}
+
+// Joins conditions into a WHERE clause
+func whereClause(conditions []string) string {
+	if len(conditions) == 0 {
+		return ""
+	}
+	return " WHERE " + strings.Join(conditions, " AND ")
+}
+
 // Middleware for logging requests
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.Method, r.RequestURI, r.RemoteAddr)
+		log.Printf("%s %s %s %s", requestID(r), r.Method, redactedURI(r), r.RemoteAddr)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Returns the URI of a request with the value of an access_token parameter
+// hidden, so tokens do not end up in the logs
+func redactedURI(r *http.Request) string {
+	query := r.URL.Query()
+	if !query.Has("access_token") {
+		return r.RequestURI
+	}
+	query.Set("access_token", "REDACTED")
+	return r.URL.Path + "?" + query.Encode()
+}
+
+// Middleware that takes the token from the access_token query parameter when
+// there is no Authorization header, for clients such as EventSource that
+// cannot set headers
+func queryTokenMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", token)
+		}
 		next.ServeHTTP(w, r)
 	})
 }
@@ -134,7 +493,7 @@ func authenticateMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		tokenStr := r.Header.Get("Authorization")
 		if tokenStr == "" {
-			http.Error(w, "Missing token", http.StatusUnauthorized)
+			httpError(w, r, http.StatusUnauthorized, "error.missing_token")
 			return
 		}
 
@@ -143,7 +502,7 @@ func authenticateMiddleware(next http.Handler) http.Handler {
 			return jwtKey, nil
 		})
 		if err != nil || !token.Valid {
-			http.Error(w, "Invalid token", http.StatusUnauthorized)
+			httpError(w, r, http.StatusUnauthorized, "error.invalid_token")
 			return
 		}
 
@@ -152,62 +511,108 @@ func authenticateMiddleware(next http.Handler) http.Handler {
 }
 
 func main() {
-	db, err := sql.Open("sqlite3", "./tasks.db")
+	lang := flag.String("lang", "", "default language of the error messages (en or es); defaults to LC_ALL, LC_MESSAGES or LANG")
+	requireIfMatch := flag.Bool("require-if-match", false, "reject task writes without an If-Match header")
+	maxBatchSize := flag.Int("max-batch-size", DefaultMaxBatchSize, "maximum number of operations in a batch")
+	serverConfig := RegisterServerFlags(flag.CommandLine)
+	flag.Parse()
+	serverLocale = SelectLocale(*lang)
+	if *maxBatchSize < 1 {
+		log.Fatalf("invalid -max-batch-size: %d; a batch must allow at least 1 operation", *maxBatchSize)
+	}
+
+	db, err := openDatabase("./tasks.db")
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer db.Close()
 
 	tm := NewTaskManager(db)
+	tm.RequirePreconditions = *requireIfMatch
+	tm.MaxBatchSize = *maxBatchSize
 	if err := tm.InitializeDB(); err != nil {
 		log.Fatal(err)
 	}
 
-	http.HandleFunc("/register", func(w http.ResponseWriter, r *http.Request) {
+	// Wrap the router with the request ID and logging middleware; the task
+	// routes also require a token
+	loggedRouter := requestIDMiddleware(loggingMiddleware(newRouter(tm)))
+
+	serveErr := serverConfig.ListenAndServe(loggedRouter, tm.Events().Close)
+
+	// No request uses the database once the server has stopped
+	if err := db.Close(); err != nil {
+		log.Printf("could not close database: %v", err)
+	}
+	if serveErr != nil {
+		log.Fatalf("server stopped: %v\n", serveErr)
+	}
+}
+
+// newRouter declares the routes of the task API. Registering, logging in and
+// the documentation are public; the task routes are in a group behind
+// authenticateMiddleware.
+func newRouter(tm *TaskManager) *Router {
+	router := NewRouter()
+
+	router.HandleFunc("POST /register", func(w http.ResponseWriter, r *http.Request) {
 		var creds Credentials
-		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if !decodeBody(w, r, &creds) {
+			return
+		}
+		var errs []*fieldError
+		if creds.Username == "" {
+			errs = append(errs, &fieldError{field: "username", key: "field.required"})
+		}
+		if creds.Password == "" {
+			errs = append(errs, &fieldError{field: "password", key: "field.required"})
+		}
+		if len(errs) > 0 {
+			validationError(w, r, http.StatusUnprocessableEntity, "error.invalid_user", errs...)
 			return
 		}
 
 		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			internalError(w, r, err)
 			return
 		}
 
 		query := `INSERT INTO users (username, password) VALUES (?, ?)`
-		_, err = db.Exec(query, creds.Username, hashedPassword)
+		_, err = tm.db.Exec(query, creds.Username, hashedPassword)
+		var sqliteErr sqlite3.Error
+		if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+			httpError(w, r, http.StatusConflict, "error.username_taken", creds.Username)
+			return
+		}
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			internalError(w, r, err)
 			return
 		}
 
 		w.WriteHeader(http.StatusCreated)
 	})
 
-	http.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+	router.HandleFunc("POST /login", func(w http.ResponseWriter, r *http.Request) {
 		var creds Credentials
-		if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+		if !decodeBody(w, r, &creds) {
 			return
 		}
 
 		var storedCreds Credentials
 		query := `SELECT username, password FROM users WHERE username = ?`
-		row := db.QueryRow(query, creds.Username)
+		row := tm.db.QueryRow(query, creds.Username)
 		err := row.Scan(&storedCreds.Username, &storedCreds.Password)
 		if err != nil {
 			if err == sql.ErrNoRows {
-				http.Error(w, "User not found", http.StatusUnauthorized)
+				httpError(w, r, http.StatusUnauthorized, "error.user_not_found")
 				return
 			}
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			internalError(w, r, err)
 			return
 		}
 
 		if err := bcrypt.CompareHashAndPassword([]byte(storedCreds.Password), []byte(creds.Password)); err != nil {
-			http.Error(w, "Invalid credentials", http.StatusUnauthorized)
+			httpError(w, r, http.StatusUnauthorized, "error.invalid_credentials")
 			return
 		}
 
@@ -222,85 +627,142 @@ func main() {
 		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 		tokenStr, err := token.SignedString(jwtKey)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+			internalError(w, r, err)
 			return
 		}
 
 		jsonResponse(w, map[string]string{"token": tokenStr}, http.StatusOK)
 	})
 
-	http.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case "GET":
-			tasks, err := tm.ListTasks()
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			jsonResponse(w, tasks, http.StatusOK)
-		case "POST":
-			var task Task
-			if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			newTask, err := tm.AddTask(task.Description)
-			if err != nil {
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				return
-			}
-			jsonResponse(w, newTask, http.StatusCreated)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	authenticated := router.Group(authenticateMiddleware)
+
+	authenticated.HandleFunc("GET /tasks", func(w http.ResponseWriter, r *http.Request) {
+		query, err := ParseTaskQuery(r.URL.Query())
+		if err != nil {
+			invalidRequest(w, r, http.StatusBadRequest, "error.invalid_query", err)
+			return
 		}
+		revision, err := tm.Revision()
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		if notModified(w, r, revision.ETag(), revision.UpdatedAt) {
+			return
+		}
+		page, err := tm.QueryTasks(query)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		setPageHeaders(w, r, page)
+		jsonResponse(w, page.Tasks, http.StatusOK)
 	})
 
-	http.HandleFunc("/tasks/", func(w http.ResponseWriter, r *http.Request) {
-		idStr := r.URL.Path[len("/tasks/"):]
-		id, err := strconv.Atoi(idStr)
+	// Browsers can set no headers on EventSource and WebSocket connections
+	streams := router.Group(queryTokenMiddleware, authenticateMiddleware)
+	streams.HandleFunc("GET /tasks/events", taskEventsHandler(tm.Events()))
+	streams.HandleFunc("GET /tasks/ws", taskSocketHandler(tm))
+
+	authenticated.HandleFunc("POST /tasks", func(w http.ResponseWriter, r *http.Request) {
+		var task Task
+		if !decodeBody(w, r, &task) {
+			return
+		}
+		if err := validateTask(&task); err != nil {
+			invalidRequest(w, r, http.StatusUnprocessableEntity, "error.invalid_task", err)
+			return
+		}
+		newTask, err := tm.AddTask(task.Description)
 		if err != nil {
-			http.Error(w, "Invalid task ID", http.StatusBadRequest)
+			internalError(w, r, err)
 			return
 		}
+		taskResponse(w, newTask, http.StatusCreated)
+	})
 
-		switch r.Method {
-		case "GET":
-			task, err := tm.GetTask(id)
-			if err != nil {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			jsonResponse(w, task, http.StatusOK)
-		case "PUT":
-			var task Task
-			if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			updatedTask, err := tm.UpdateTask(id, task.Description, task.Completed)
-			if err != nil {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			jsonResponse(w, updatedTask, http.StatusOK)
-		case "DELETE":
-			if err := tm.DeleteTask(id); err != nil {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			w.WriteHeader(http.StatusNoContent)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	authenticated.HandleFunc("POST /tasks/batch", taskBatchHandler(tm))
+
+	authenticated.HandleFunc("GET /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
+			return
 		}
+		task, err := tm.GetTask(id)
+		if err != nil {
+			taskError(w, r, err)
+			return
+		}
+		if notModified(w, r, taskETag(task), task.UpdatedAt) {
+			return
+		}
+		taskResponse(w, task, http.StatusOK)
 	})
 
-	// Wrap the default mux with the logging and authentication middleware
-	loggedMux := loggingMiddleware(authenticateMiddleware(http.DefaultServeMux))
+	authenticated.HandleFunc("PUT /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
+			return
+		}
+		var task Task
+		if !decodeBody(w, r, &task) {
+			return
+		}
+		if err := validateTask(&task); err != nil {
+			invalidRequest(w, r, http.StatusUnprocessableEntity, "error.invalid_task", err)
+			return
+		}
+		updatedTask, err := tm.UpdateTask(id, task.Description, task.Completed, ParseIfMatch(r))
+		if err != nil {
+			taskError(w, r, err)
+			return
+		}
+		taskResponse(w, updatedTask, http.StatusOK)
+	})
 
-	fmt.Println("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", loggedMux); err != nil {
-		log.Fatalf("could not start server: %v\n", err)
-	}
+	authenticated.HandleFunc("PATCH /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
+			return
+		}
+		// The body is read before the transaction begins, so a slow client
+		// cannot hold up other writes
+		patch, err := io.ReadAll(limitBody(w, r))
+		if err != nil {
+			patchError(w, r, err)
+			return
+		}
+		patchedTask, err := tm.ApplyPatch(id, r.Header.Get("Content-Type"), patch, ParseIfMatch(r))
+		var invalid *invalidPatchError
+		if errors.As(err, &invalid) {
+			patchError(w, r, invalid.err)
+			return
+		} else if err != nil {
+			taskError(w, r, err)
+			return
+		}
+		taskResponse(w, patchedTask, http.StatusOK)
+	})
+
+	authenticated.HandleFunc("DELETE /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
+			return
+		}
+		if err := tm.DeleteTask(id, ParseIfMatch(r)); err != nil {
+			taskError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	registerDocs(router)
+
+	return router
 }
 
 // jsonResponse encodes response as JSON and writes it to the ResponseWriter
@@ -308,6 +770,20 @@ func jsonResponse(w http.ResponseWriter, data interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("could not write response: %v", err)
+	}
+}
+
+// taskError replies to a failed read or write of a task
+func taskError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrTaskNotFound):
+		httpError(w, r, http.StatusNotFound, "error.task_not_found")
+	case errors.Is(err, ErrVersionMismatch):
+		httpError(w, r, http.StatusPreconditionFailed, "error.version_mismatch")
+	case errors.Is(err, ErrPreconditionRequired):
+		httpError(w, r, http.StatusPreconditionRequired, "error.precondition_required")
+	default:
+		internalError(w, r, err)
 	}
 }
@@ -0,0 +1,781 @@
+package main
+
+import (
+	"bufio"
+	"database/sql"
+	"encoding/json"
+	"github.com/gorilla/websocket"
+	_ "github.com/mattn/go-sqlite3"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setupTestDB(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection to :memory: opens a new database
+	db.SetMaxOpenConns(1)
+
+	tm := NewTaskManager(db)
+	if err := tm.InitializeDB(); err != nil {
+		t.Fatal(err)
+	}
+
+	return db
+}
+
+// Opens a file database like the server's, so that concurrent writers use
+// their own connections
+func setupFileDB(t *testing.T) *sql.DB {
+	db, err := openDatabase(filepath.Join(t.TempDir(), "tasks.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewTaskManager(db).InitializeDB(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// Registers and logs in a user through the router and returns the token
+func loginTestUser(t *testing.T, router http.Handler) string {
+	body := `{"username": "testuser", "password": "password"}`
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/register", strings.NewReader(body)))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status code %d registering, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/login", strings.NewReader(body)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d logging in, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var response map[string]string
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+	return response["token"]
+}
+
+func TestTaskRoutesRequireToken(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := newRouter(NewTaskManager(db))
+	token := loginTestUser(t, router)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/tasks", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected status code %d without a token, got %d", http.StatusUnauthorized, rr.Code)
+	}
+
+	req := httptest.NewRequest("POST", "/tasks", strings.NewReader(`{"description": "New Task"}`))
+	req.Header.Set("Authorization", token)
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Errorf("expected status code %d with a token, got %d", http.StatusCreated, rr.Code)
+	}
+}
+
+func TestRouterRejectsUnknownPathsAndMethods(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := newRouter(NewTaskManager(db))
+	token := loginTestUser(t, router)
+
+	req := httptest.NewRequest("GET", "/tasks/1/extra", nil)
+	req.Header.Set("Authorization", token)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected status code %d, got %d", http.StatusNotFound, rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/login", nil))
+	if rr.Code != http.StatusMethodNotAllowed || rr.Header().Get("Allow") != "OPTIONS, POST" {
+		t.Errorf("expected status code %d with Allow \"OPTIONS, POST\", got %d with %q",
+			http.StatusMethodNotAllowed, rr.Code, rr.Header().Get("Allow"))
+	}
+}
+
+func TestPatchTaskWritesOnlyChangedFields(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tm := NewTaskManager(db)
+	router := newRouter(tm)
+	token := loginTestUser(t, router)
+	task, err := tm.AddTask("Test Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("PATCH", "/tasks/"+strconv.Itoa(task.ID), strings.NewReader(`{"completed": true}`))
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Content-Type", "application/merge-patch+json")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+
+	patched, err := tm.GetTask(task.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if patched.Description != "Test Task" || !patched.Completed {
+		t.Errorf("expected the description to be kept and the task completed, got %+v", patched)
+	}
+
+	req = httptest.NewRequest("PATCH", "/tasks/"+strconv.Itoa(task.ID), strings.NewReader(`[{"op": "replace", "path": "/id", "value": 99}]`))
+	req.Header.Set("Authorization", token)
+	req.Header.Set("Content-Type", "application/json-patch+json")
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status code %d changing the id, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+}
+
+func TestConcurrentPatchesWithoutIfMatchSucceed(t *testing.T) {
+	db := setupFileDB(t)
+	defer db.Close()
+
+	tm := NewTaskManager(db)
+	router := newRouter(tm)
+	token := loginTestUser(t, router)
+	task, err := tm.AddTask("Test Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Without If-Match a patch must not fail because another write came first
+	const writers = 100
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := `{"description": "Patch ` + strconv.Itoa(i) + `"}`
+			req := httptest.NewRequest("PATCH", "/tasks/"+strconv.Itoa(task.ID), strings.NewReader(body))
+			req.Header.Set("Authorization", token)
+			req.Header.Set("Content-Type", "application/merge-patch+json")
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Errorf("expected status code %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if patched, err := tm.GetTask(task.ID); err != nil || patched.Version != 1+writers {
+		t.Errorf("expected version %d, got %+v, %v", 1+writers, patched, err)
+	}
+}
+
+func TestQueryTasksPagesInSQL(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tm := NewTaskManager(db)
+	for _, description := range []string{"b", "a", "c", "a", "d"} {
+		if _, err := tm.AddTask(description); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tm.UpdateTask(5, "d", true, Precondition{}); err != nil {
+		t.Fatal(err)
+	}
+
+	completed := false
+	query := TaskQuery{Completed: &completed, Sort: "description", Limit: 3}
+	first, err := tm.QueryTasks(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.Total != 4 || len(first.Tasks) != 3 || first.Next == "" || first.Prev != "" {
+		t.Fatalf("unexpected first page: %+v", first)
+	}
+	if first.Tasks[0].ID != 2 || first.Tasks[1].ID != 4 || first.Tasks[2].ID != 1 {
+		t.Errorf("expected tasks 2, 4 and 1 ordered by description and ID, got %d, %d and %d",
+			first.Tasks[0].ID, first.Tasks[1].ID, first.Tasks[2].ID)
+	}
+
+	query.Cursor, _ = decodeCursor(first.Next)
+	second, err := tm.QueryTasks(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(second.Tasks) != 1 || second.Tasks[0].ID != 3 || second.Next != "" || second.Prev == "" {
+		t.Fatalf("unexpected second page: %+v", second)
+	}
+
+	query.Cursor, _ = decodeCursor(second.Prev)
+	back, err := tm.QueryTasks(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Tasks) != 3 || back.Tasks[0].ID != 2 || back.Prev != "" {
+		t.Errorf("expected the previous link to return to the first page, got %+v", back)
+	}
+}
+
+func TestErrorsAreProblemDetails(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	router := requestIDMiddleware(newRouter(NewTaskManager(db)))
+	loginTestUser(t, router)
+
+	req := httptest.NewRequest("POST", "/register", strings.NewReader(`{"username": "testuser", "password": "other"}`))
+	req.Header.Set("X-Request-ID", "register-1")
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected status code %d for a taken username, got %d: %s", http.StatusConflict, rr.Code, rr.Body.String())
+	}
+	if rr.Header().Get("Content-Type") != problemContentType {
+		t.Errorf("expected Content-Type %q, got %q", problemContentType, rr.Header().Get("Content-Type"))
+	}
+	var problem Problem
+	if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Type != "/problems/username-taken" || problem.RequestID != "register-1" {
+		t.Errorf("expected a username-taken problem for request register-1, got %+v", problem)
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("POST", "/register", strings.NewReader(`{"username": "", "password": 1}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected status code %d for a password that is not a string, got %d", http.StatusBadRequest, rr.Code)
+	}
+	problem = Problem{}
+	if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "password" {
+		t.Errorf("expected an error for the password field, got %+v", problem.Errors)
+	}
+}
+
+func TestWritesHonourIfMatch(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tm := NewTaskManager(db)
+	router := newRouter(tm)
+	token := loginTestUser(t, router)
+	task, err := tm.AddTask("Test Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "/tasks/" + strconv.Itoa(task.ID)
+
+	steps := []struct {
+		method  string
+		body    string
+		ifMatch string
+		status  int
+		etag    string
+	}{
+		{"GET", "", "", http.StatusOK, `"1"`},
+		{"PUT", `{"description": "Updated"}`, `"1"`, http.StatusOK, `"2"`},
+		{"PUT", `{"description": "Lost update"}`, `"1"`, http.StatusPreconditionFailed, ""},
+		{"PATCH", `{"completed": true}`, `"1"`, http.StatusPreconditionFailed, ""},
+		{"PATCH", `{"completed": true}`, `"2"`, http.StatusOK, `"3"`},
+		{"DELETE", "", `W/"3"`, http.StatusPreconditionFailed, ""},
+		{"DELETE", "", `"3"`, http.StatusNoContent, ""},
+		{"DELETE", "", `"3"`, http.StatusNotFound, ""},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, path, strings.NewReader(step.body))
+		req.Header.Set("Authorization", token)
+		req.Header.Set("Content-Type", "application/json")
+		if step.ifMatch != "" {
+			req.Header.Set("If-Match", step.ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != step.status || rr.Header().Get("ETag") != step.etag {
+			t.Errorf("%s with If-Match %s: expected status %d and ETag %q, got %d and %q",
+				step.method, step.ifMatch, step.status, step.etag, rr.Code, rr.Header().Get("ETag"))
+		}
+	}
+
+	tm.RequirePreconditions = true
+	task, err = tm.AddTask("Guarded Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tm.UpdateTask(task.ID, "Unguarded", false, Precondition{}); err != ErrPreconditionRequired {
+		t.Errorf("expected ErrPreconditionRequired without a precondition, got %v", err)
+	}
+	if _, err := tm.UpdateTask(task.ID, "Guarded", false, IfVersion(task.Version)); err != nil {
+		t.Errorf("expected the update to succeed with the current version, got %v", err)
+	}
+}
+
+func TestInitializeDBMigratesOldTasksTable(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	db.SetMaxOpenConns(1)
+
+	// A tasks table created before tasks had versions
+	if _, err := db.Exec(`CREATE TABLE tasks (id INTEGER PRIMARY KEY AUTOINCREMENT, description TEXT, completed BOOLEAN, created_at DATETIME)`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`INSERT INTO tasks (description, completed, created_at) VALUES ('Old Task', 0, ?)`, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	tm := NewTaskManager(db)
+	if err := tm.InitializeDB(); err != nil {
+		t.Fatal(err)
+	}
+	task, err := tm.GetTask(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Version != 1 || !task.UpdatedAt.Equal(task.CreatedAt) {
+		t.Errorf("expected existing tasks to start at version 1, updated when created, got %+v", task)
+	}
+}
+
+func TestConditionalGetUsesRevision(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tm := NewTaskManager(db)
+	router := newRouter(tm)
+	token := loginTestUser(t, router)
+	task, err := tm.AddTask("Test Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", token)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	path := "/tasks/" + strconv.Itoa(task.ID)
+	listETag := get("/tasks", "").Header().Get("ETag")
+	taskETag := get(path, "").Header().Get("ETag")
+	if rr := get("/tasks?completed=false", listETag); rr.Code != http.StatusNotModified {
+		t.Errorf("expected status code %d for an unchanged list, got %d", http.StatusNotModified, rr.Code)
+	}
+	if rr := get(path, taskETag); rr.Code != http.StatusNotModified {
+		t.Errorf("expected status code %d for an unchanged task, got %d", http.StatusNotModified, rr.Code)
+	}
+
+	other, err := tm.AddTask("Other Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.DeleteTask(other.ID, Precondition{}); err != nil {
+		t.Fatal(err)
+	}
+	if rr := get("/tasks", listETag); rr.Code != http.StatusOK || rr.Header().Get("ETag") == listETag {
+		t.Errorf("expected a new ETag after tasks were added and deleted, got %d with %q", rr.Code, rr.Header().Get("ETag"))
+	}
+	if rr := get(path, taskETag); rr.Code != http.StatusNotModified {
+		t.Errorf("expected writes to other tasks to keep the task current, got %d", rr.Code)
+	}
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	var document struct {
+		OpenAPI    string                            `json:"openapi"`
+		Paths      map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(openAPISpec, &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.OpenAPI != "3.1.0" {
+		t.Errorf("expected OpenAPI 3.1.0, got %q", document.OpenAPI)
+	}
+
+	var documented []string
+	for path, item := range document.Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	router := newRouter(NewTaskManager(db))
+	routes := router.Routes()
+	sort.Strings(documented)
+	sort.Strings(routes)
+	if !slices.Equal(documented, routes) {
+		t.Errorf("expected the document to describe the routes\n%v\ngot\n%v", routes, documented)
+	}
+
+	for schema, value := range map[string]interface{}{"Task": Task{}, "Credentials": Credentials{}} {
+		var fields, properties []string
+		valueType := reflect.TypeOf(value)
+		for i := 0; i < valueType.NumField(); i++ {
+			fields = append(fields, strings.Split(valueType.Field(i).Tag.Get("json"), ",")[0])
+		}
+		for name := range document.Components.Schemas[schema].Properties {
+			properties = append(properties, name)
+		}
+		sort.Strings(fields)
+		sort.Strings(properties)
+		if !slices.Equal(fields, properties) {
+			t.Errorf("expected the %s schema to have the fields %v, got %v", schema, fields, properties)
+		}
+	}
+
+	// The documentation is public
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status code %d for the document without a token, got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestTaskEventsRequireTokenAndResume(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tm := NewTaskManager(db)
+	server := httptest.NewServer(newRouter(tm))
+	defer server.Close()
+	token := loginTestUser(t, server.Config.Handler)
+
+	resp, err := http.Get(server.URL + "/tasks/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status code %d without a token, got %d", http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	_, events, _ := tm.Events().Subscribe("")
+	task, err := tm.AddTask("Test Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := <-events
+	tm.Events().Unsubscribe(events)
+
+	if _, err := tm.ApplyPatch(task.ID, mergePatchType, []byte(`{"completed": true}`), Precondition{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tm.UpdateTask(task.ID, "Updated Task", true, Precondition{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tm.DeleteTask(task.ID, Precondition{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// EventSource cannot set headers, so it reconnects with query parameters
+	resp, err = http.Get(server.URL + "/tasks/events?access_token=" + token + "&lastEventId=" + created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status code %d with a token in the query, got %d", http.StatusOK, resp.StatusCode)
+	}
+	stream := bufio.NewReader(resp.Body)
+	var types []string
+	for len(types) < 3 {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event, ok := strings.CutPrefix(line, "event: "); ok {
+			types = append(types, strings.TrimSpace(event))
+		}
+	}
+	if !slices.Equal(types, []string{TaskUpdated, TaskUpdated, TaskDeleted}) {
+		t.Errorf("expected the missed updates and delete, got %v", types)
+	}
+}
+
+func TestEventsCarryTheRowTheirWriteReturned(t *testing.T) {
+	db := setupFileDB(t)
+	defer db.Close()
+
+	tm := NewTaskManager(db)
+	task, err := tm.AddTask("Test Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, events, _ := tm.Events().Subscribe("")
+	defer tm.Events().Unsubscribe(events)
+
+	// Concurrent writers must not publish a row a later write produced
+	const writers = 50
+	written := make(chan *Task, writers)
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			updated, err := tm.UpdateTask(task.ID, "Update "+strconv.Itoa(i), false, Precondition{})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			written <- updated
+		}(i)
+	}
+	wg.Wait()
+	close(written)
+
+	byVersion := map[int]string{}
+	for updated := range written {
+		byVersion[updated.Version] = updated.Description
+	}
+	for i := 0; i < writers; i++ {
+		event := <-events
+		if description := byVersion[event.Task.Version]; event.Task.Description != description {
+			t.Errorf("expected version %d to be %q, got %q", event.Task.Version, description, event.Task.Description)
+		}
+		delete(byVersion, event.Task.Version)
+	}
+	if len(byVersion) != 0 {
+		t.Errorf("expected an event per version, missing %v", byVersion)
+	}
+}
+
+func TestTaskSocketRequiresTokenAndWrites(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tm := NewTaskManager(db)
+	server := httptest.NewServer(newRouter(tm))
+	defer server.Close()
+	token := loginTestUser(t, server.Config.Handler)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/tasks/ws"
+	if _, resp, err := websocket.DefaultDialer.Dial(url, nil); err == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the upgrade to be refused without a token, got %v", err)
+	}
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?access_token="+token, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	send := func(message wsMessage) wsMessage {
+		t.Helper()
+		if err := conn.WriteJSON(message); err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		var reply wsMessage
+		if err := conn.ReadJSON(&reply); err != nil {
+			t.Fatal(err)
+		}
+		return reply
+	}
+
+	created := send(wsMessage{ID: "1", Type: wsCreate, Task: &Task{Description: "Test Task"}})
+	if created.Type != "ack" || created.Task == nil || created.Task.Version != 1 {
+		t.Fatalf("expected an ack with the stored task, got %+v", created)
+	}
+	patched := send(wsMessage{ID: "2", Type: wsPatch, TaskID: created.Task.ID, Version: 1,
+		Patch: []byte(`[{"op": "replace", "path": "/completed", "value": true}]`)})
+	if patched.Type != "ack" || !patched.Task.Completed || patched.Task.Version != 2 {
+		t.Errorf("expected an ack with the patched task, got %+v", patched)
+	}
+	if task, err := tm.GetTask(created.Task.ID); err != nil || !task.Completed {
+		t.Errorf("expected the patch to be stored, got %+v, %v", task, err)
+	}
+	missing := send(wsMessage{ID: "3", Type: wsDelete, TaskID: created.Task.ID + 1})
+	if missing.Type != "error" || missing.ID != "3" || missing.Error.Status != http.StatusNotFound {
+		t.Errorf("expected a 404 error for a missing task, got %+v", missing)
+	}
+}
+
+func TestConcurrentSocketPatchesWithoutVersionSucceed(t *testing.T) {
+	db := setupFileDB(t)
+	defer db.Close()
+
+	tm := NewTaskManager(db)
+	server := httptest.NewServer(newRouter(tm))
+	defer server.Close()
+	token := loginTestUser(t, server.Config.Handler)
+	task, err := tm.AddTask("Test Task")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Several clients patch the same task without a version; none may get a
+	// precondition error because another client wrote first
+	const clients, patches = 10, 20
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/tasks/ws?access_token=" + token
+	var wg sync.WaitGroup
+	for c := 0; c < clients; c++ {
+		conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		wg.Add(1)
+		go func(c int) {
+			defer wg.Done()
+			conn.SetReadDeadline(time.Now().Add(30 * time.Second))
+			for i := 0; i < patches; i++ {
+				patch := []byte(`{"description": "Client ` + strconv.Itoa(c) + ` patch ` + strconv.Itoa(i) + `"}`)
+				var reply wsMessage
+				if err := conn.WriteJSON(wsMessage{ID: strconv.Itoa(i), Type: wsPatch, TaskID: task.ID, Patch: patch}); err != nil {
+					t.Error(err)
+					return
+				}
+				if err := conn.ReadJSON(&reply); err != nil || reply.Type != "ack" {
+					t.Errorf("expected an ack, got %s %+v, %v", reply.Type, reply.Error, err)
+					return
+				}
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	if patched, err := tm.GetTask(task.ID); err != nil || patched.Version != 1+clients*patches {
+		t.Errorf("expected version %d, got %+v, %v", 1+clients*patches, patched, err)
+	}
+}
+
+func TestBatchRunsInOneTransaction(t *testing.T) {
+	db := setupTestDB(t)
+	defer db.Close()
+
+	tm := NewTaskManager(db)
+	tm.MaxBatchSize = 3
+	router := newRouter(tm)
+	token := loginTestUser(t, router)
+
+	runBatch := func(body string) (int, BatchResponse) {
+		t.Helper()
+		req := httptest.NewRequest("POST", "/tasks/batch", strings.NewReader(body))
+		req.Header.Set("Authorization", token)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		var response BatchResponse
+		json.NewDecoder(rr.Body).Decode(&response)
+		return rr.Code, response
+	}
+	statuses := func(response BatchResponse) []int {
+		var result []int
+		for _, r := range response.Results {
+			result = append(result, r.Status)
+		}
+		return result
+	}
+	countTasks := func() int {
+		var count int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM tasks`).Scan(&count); err != nil {
+			t.Fatal(err)
+		}
+		return count
+	}
+
+	operations := `[
+		{"op": "create", "task": {"description": "First"}},
+		{"op": "update", "id": 99, "task": {"description": "Missing"}},
+		{"op": "create", "task": {"description": "Second"}}
+	]`
+	code, response := runBatch(`{"operations": ` + operations + `}`)
+	if code != http.StatusNotFound || response.Committed || !slices.Equal(statuses(response), []int{424, 404, 424}) {
+		t.Errorf("expected an atomic batch to roll back on the missing task, got %d %+v", code, response)
+	}
+	if count := countTasks(); count != 0 {
+		t.Errorf("expected no task to be written, got %d", count)
+	}
+
+	code, response = runBatch(`{"mode": "best_effort", "operations": ` + operations + `}`)
+	if code != http.StatusOK || !response.Committed || !slices.Equal(statuses(response), []int{201, 404, 201}) {
+		t.Errorf("expected a best-effort batch to write the other operations, got %d %+v", code, response)
+	}
+	if count := countTasks(); count != 2 {
+		t.Errorf("expected 2 tasks to be written, got %d", count)
+	}
+
+	first := response.Results[0].Task
+	code, response = runBatch(`{"operations": [
+		{"op": "update", "id": ` + strconv.Itoa(first.ID) + `, "version": 1, "task": {"description": "First", "completed": true}},
+		{"op": "delete", "id": ` + strconv.Itoa(response.Results[2].Task.ID) + `}
+	]}`)
+	if code != http.StatusOK || !slices.Equal(statuses(response), []int{200, 204}) || !response.Results[0].Task.Completed {
+		t.Errorf("expected the update and delete to be written, got %d %+v", code, response)
+	}
+
+	code, _ = runBatch(`{"operations": [{"op": "delete", "id": 1}, {"op": "delete", "id": 2}, {"op": "delete", "id": 3}, {"op": "delete", "id": 4}]}`)
+	if code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status code %d for a batch over the limit, got %d", http.StatusRequestEntityTooLarge, code)
+	}
+
+	// Decoding stops at the first operation past the limit
+	operation := `{"op": "delete", "id": 1},`
+	body := &countingReader{r: io.MultiReader(strings.NewReader(`{"operations": [`), strings.NewReader(strings.Repeat(operation, 100000)))}
+	req := httptest.NewRequest("POST", "/tasks/batch", body)
+	req.Header.Set("Authorization", token)
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, req)
+	if rr.Code != http.StatusRequestEntityTooLarge || body.n > 64<<10 {
+		t.Errorf("expected a 413 after reading little of the batch, got %d after %d bytes", rr.Code, body.n)
+	}
+
+	code, _ = runBatch(`{"mode": "` + strings.Repeat("x", MaxBodySize) + `"}`)
+	if code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status code %d for a body over the limit, got %d", http.StatusRequestEntityTooLarge, code)
+	}
+	code, _ = runBatch(`{"operations": {"op": "delete", "id": 1}}`)
+	if code != http.StatusBadRequest {
+		t.Errorf("expected status code %d for operations that are not an array, got %d", http.StatusBadRequest, code)
+	}
+}
+
+// Counts the bytes read from r
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	return n, err
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Patch media types
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+)
+
+var (
+	errUnsupportedPatch = errors.New("unsupported patch format")
+	errPatchTestFailed  = errors.New("patch test failed")
+	errMalformedPatch   = errors.New("patch is not valid JSON")
+)
+
+// patchOperationError is an operation of a JSON Patch that cannot be applied,
+// described by a message key that is translated when the response is written
+type patchOperationError struct {
+	index int
+	key   string
+	args  []interface{}
+}
+
+func (e *patchOperationError) Error() string {
+	return fmt.Sprintf("operation %d: %s", e.index, NewTranslator(DefaultLocale).T(e.key, e.args...))
+}
+
+// problemArgs returns the arguments of the error.invalid_patch message in the
+// language of tr
+func (e *patchOperationError) problemArgs(tr *Translator) []interface{} {
+	return []interface{}{e.index, tr.T(e.key, e.args...)}
+}
+
+// Records the index of the operation that failed in its error
+func atOperation(index int, err error) error {
+	var operationErr *patchOperationError
+	if errors.As(err, &operationErr) {
+		operationErr.index = index
+	}
+	return err
+}
+
+// TaskChanges holds the fields of a task that a patch changed; nil fields
+// stay the same
+type TaskChanges struct {
+	Description *string
+	Completed   *bool
+}
+
+// Empty returns true if no field changed
+func (c TaskChanges) Empty() bool {
+	return c.Description == nil && c.Completed == nil
+}
+
+// A JSON Patch operation
+type patchOperation struct {
+	Op    string           `json:"op"`
+	Path  string           `json:"path"`
+	From  string           `json:"from"`
+	Value *json.RawMessage `json:"value"`
+}
+
+// patchTask applies a JSON Merge Patch (RFC 7386) or a JSON Patch (RFC 6902)
+// to a task, chosen by the content type, and returns the fields that
+// changed. A plain application/json body is read as a merge patch.
+func patchTask(task *Task, contentType string, body io.Reader) (TaskChanges, error) {
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return TaskChanges{}, err
+	}
+
+	var document interface{}
+	original, err := json.Marshal(task)
+	if err != nil {
+		return TaskChanges{}, err
+	}
+	if err := json.Unmarshal(original, &document); err != nil {
+		return TaskChanges{}, err
+	}
+
+	switch mediaType {
+	case mergePatchType, "application/json":
+		var patch interface{}
+		if err := json.Unmarshal(data, &patch); err != nil {
+			return TaskChanges{}, errMalformedPatch
+		}
+		document = applyMergePatch(document, patch)
+	case jsonPatchType:
+		var operations []patchOperation
+		if err := json.Unmarshal(data, &operations); err != nil {
+			return TaskChanges{}, errMalformedPatch
+		}
+		if document, err = applyJSONPatch(document, operations); err != nil {
+			return TaskChanges{}, err
+		}
+	default:
+		return TaskChanges{}, errUnsupportedPatch
+	}
+
+	return taskChanges(task, document)
+}
+
+// Validates the patched document and compares it with the task
+func taskChanges(task *Task, document interface{}) (TaskChanges, error) {
+	patched, err := json.Marshal(document)
+	if err != nil {
+		return TaskChanges{}, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	var result Task
+	if err := decoder.Decode(&result); err != nil {
+		return TaskChanges{}, decodeFieldError(err)
+	}
+	if result.ID != task.ID {
+		return TaskChanges{}, &fieldError{field: "id", key: "field.read_only"}
+	}
+	if !result.CreatedAt.Equal(task.CreatedAt) {
+		return TaskChanges{}, &fieldError{field: "created_at", key: "field.read_only"}
+	}
+	if !result.UpdatedAt.Equal(task.UpdatedAt) {
+		return TaskChanges{}, &fieldError{field: "updated_at", key: "field.read_only"}
+	}
+	if result.Version != task.Version {
+		return TaskChanges{}, &fieldError{field: "version", key: "field.read_only"}
+	}
+	if err := validateTask(&result); err != nil {
+		return TaskChanges{}, err
+	}
+
+	var changes TaskChanges
+	if result.Description != task.Description {
+		changes.Description = &result.Description
+	}
+	if result.Completed != task.Completed {
+		changes.Completed = &result.Completed
+	}
+	return changes, nil
+}
+
+// Applies a JSON Merge Patch to a document
+func applyMergePatch(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = applyMergePatch(targetObject[name], value)
+	}
+	return targetObject
+}
+
+// Applies the operations of a JSON Patch to a document in order
+func applyJSONPatch(document interface{}, operations []patchOperation) (interface{}, error) {
+	var err error
+	for i, operation := range operations {
+		var value interface{}
+		switch operation.Op {
+		case "add", "replace", "test":
+			if operation.Value == nil {
+				return nil, &patchOperationError{index: i, key: "patch.needs_value", args: []interface{}{operation.Op}}
+			}
+			if err := json.Unmarshal(*operation.Value, &value); err != nil {
+				return nil, &patchOperationError{index: i, key: "patch.invalid_value"}
+			}
+		}
+
+		switch operation.Op {
+		case "add":
+			document, err = pointerAdd(document, operation.Path, value)
+		case "remove":
+			document, _, err = pointerRemove(document, operation.Path)
+		case "replace":
+			if document, _, err = pointerRemove(document, operation.Path); err == nil {
+				document, err = pointerAdd(document, operation.Path, value)
+			}
+		case "move":
+			if operation.Path == operation.From || strings.HasPrefix(operation.Path, operation.From+"/") {
+				return nil, &patchOperationError{index: i, key: "patch.move_into_itself"}
+			}
+			var moved interface{}
+			if document, moved, err = pointerRemove(document, operation.From); err == nil {
+				document, err = pointerAdd(document, operation.Path, moved)
+			}
+		case "copy":
+			var copied interface{}
+			if copied, err = pointerGet(document, operation.From); err == nil {
+				document, err = pointerAdd(document, operation.Path, deepCopy(copied))
+			}
+		case "test":
+			var current interface{}
+			if current, err = pointerGet(document, operation.Path); err == nil && !reflect.DeepEqual(current, value) {
+				return nil, errPatchTestFailed
+			}
+		default:
+			return nil, &patchOperationError{index: i, key: "patch.unknown_op", args: []interface{}{operation.Op}}
+		}
+		if err != nil {
+			return nil, atOperation(i, err)
+		}
+	}
+	return document, nil
+}
+
+// Splits a JSON Pointer (RFC 6901) into its unescaped tokens
+func parsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, &patchOperationError{key: "patch.invalid_pointer", args: []interface{}{pointer}}
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// Returns the value at a pointer
+func pointerGet(document interface{}, pointer string) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	current := document
+	for _, token := range tokens {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[token]
+			if !ok {
+				return nil, &patchOperationError{key: "patch.path_missing", args: []interface{}{pointer}}
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(node)-1)
+			if err != nil {
+				return nil, err
+			}
+			current = node[index]
+		default:
+			return nil, &patchOperationError{key: "patch.path_missing", args: []interface{}{pointer}}
+		}
+	}
+	return current, nil
+}
+
+// Adds a value at a pointer and returns the new document
+func pointerAdd(document interface{}, pointer string, value interface{}) (interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+	parent, err := pointerGet(document, pointer[:strings.LastIndex(pointer, "/")])
+	if err != nil {
+		return nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		node[last] = value
+		return document, nil
+	case []interface{}:
+		index := len(node)
+		if last != "-" {
+			if index, err = arrayIndex(last, len(node)); err != nil {
+				return nil, err
+			}
+		}
+		node = append(node[:index], append([]interface{}{value}, node[index:]...)...)
+		return replaceParent(document, pointer, node)
+	default:
+		return nil, &patchOperationError{key: "patch.path_missing", args: []interface{}{pointer}}
+	}
+}
+
+// Removes the value at a pointer and returns the new document and the value
+func pointerRemove(document interface{}, pointer string) (interface{}, interface{}, error) {
+	tokens, err := parsePointer(pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, document, nil
+	}
+	parent, err := pointerGet(document, pointer[:strings.LastIndex(pointer, "/")])
+	if err != nil {
+		return nil, nil, err
+	}
+	last := tokens[len(tokens)-1]
+	switch node := parent.(type) {
+	case map[string]interface{}:
+		value, ok := node[last]
+		if !ok {
+			return nil, nil, &patchOperationError{key: "patch.path_missing", args: []interface{}{pointer}}
+		}
+		delete(node, last)
+		return document, value, nil
+	case []interface{}:
+		index, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, nil, err
+		}
+		value := node[index]
+		node = append(node[:index:index], node[index+1:]...)
+		document, err = replaceParent(document, pointer, node)
+		return document, value, err
+	default:
+		return nil, nil, &patchOperationError{key: "patch.path_missing", args: []interface{}{pointer}}
+	}
+}
+
+// Stores a resized array back in the parent of the value at a pointer
+func replaceParent(document interface{}, pointer string, array []interface{}) (interface{}, error) {
+	parentPointer := pointer[:strings.LastIndex(pointer, "/")]
+	if parentPointer == "" {
+		return array, nil
+	}
+	grandparent, err := pointerGet(document, parentPointer[:strings.LastIndex(parentPointer, "/")])
+	if err != nil {
+		return nil, err
+	}
+	tokens, _ := parsePointer(parentPointer)
+	last := tokens[len(tokens)-1]
+	switch node := grandparent.(type) {
+	case map[string]interface{}:
+		node[last] = array
+	case []interface{}:
+		index, err := arrayIndex(last, len(node)-1)
+		if err != nil {
+			return nil, err
+		}
+		node[index] = array
+	}
+	return document, nil
+}
+
+// Parses an array index that must be between 0 and max
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || index > max || (len(token) > 1 && token[0] == '0') {
+		return 0, &patchOperationError{key: "patch.invalid_index", args: []interface{}{token}}
+	}
+	return index, nil
+}
+
+// Copies a decoded JSON value
+func deepCopy(value interface{}) interface{} {
+	switch node := value.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(node))
+		for name, child := range node {
+			copied[name] = deepCopy(child)
+		}
+		return copied
+	case []interface{}:
+		copied := make([]interface{}, len(node))
+		for i, child := range node {
+			copied[i] = deepCopy(child)
+		}
+		return copied
+	default:
+		return value
+	}
+}
+
+// patchError replies to the request with the status and message for an
+// error returned by patchTask
+func patchError(w http.ResponseWriter, r *http.Request, err error) {
+	if bodyTooLarge(w, r, err) {
+		return
+	}
+	var invalid *fieldError
+	var operationErr *patchOperationError
+	switch {
+	case errors.Is(err, errUnsupportedPatch):
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		httpError(w, r, http.StatusUnsupportedMediaType, "error.unsupported_patch", r.Header.Get("Content-Type"))
+	case errors.Is(err, errPatchTestFailed):
+		httpError(w, r, http.StatusConflict, "error.patch_test_failed")
+	case errors.As(err, &invalid):
+		validationError(w, r, http.StatusUnprocessableEntity, "error.invalid_task", invalid)
+	case errors.Is(err, errMalformedPatch):
+		httpError(w, r, http.StatusBadRequest, "error.invalid_body")
+	case errors.As(err, &operationErr):
+		httpError(w, r, http.StatusBadRequest, "error.invalid_patch", operationErr.problemArgs(translatorFor(r))...)
+	default:
+		internalError(w, r, err)
+	}
+}
+
+// validateTask checks the fields a client can set
+func validateTask(task *Task) error {
+	if strings.TrimSpace(task.Description) == "" {
+		return &fieldError{field: "description", key: "field.required"}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrVersionMismatch is returned when a write's precondition does not
+	// match the current version of a task
+	ErrVersionMismatch = errors.New("task version does not match")
+	// ErrPreconditionRequired is returned when the store requires
+	// preconditions and a write has none
+	ErrPreconditionRequired = errors.New("precondition required")
+)
+
+// Precondition restricts a write to some versions of a task. The zero
+// Precondition allows any version.
+type Precondition struct {
+	present  bool
+	any      bool
+	versions []int
+}
+
+// IfVersion returns a precondition that allows only the given version
+func IfVersion(version int) Precondition {
+	return Precondition{present: true, versions: []int{version}}
+}
+
+// ParseIfMatch reads the If-Match header of a request. "*" allows any
+// version; otherwise the header lists the ETags of the allowed versions.
+// Weak and unknown ETags never match.
+func ParseIfMatch(r *http.Request) Precondition {
+	values := r.Header.Values("If-Match")
+	if len(values) == 0 {
+		return Precondition{}
+	}
+	precondition := Precondition{present: true}
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" {
+				precondition.any = true
+				continue
+			}
+			if version, ok := parseETag(tag); ok {
+				precondition.versions = append(precondition.versions, version)
+			}
+		}
+	}
+	return precondition
+}
+
+// Check returns nil if the precondition allows a version. A missing
+// precondition is an error only when required is true.
+func (p Precondition) Check(version int, required bool) error {
+	if !p.present {
+		if required {
+			return ErrPreconditionRequired
+		}
+		return nil
+	}
+	if p.any || slices.Contains(p.versions, version) {
+		return nil
+	}
+	return ErrVersionMismatch
+}
+
+// taskETag returns the strong ETag of a version of a task
+func taskETag(task *Task) string {
+	return versionETag(task.Version)
+}
+
+// versionETag returns the strong ETag of a task version. It starts with
+// etagPrefix, so the ETags of a store whose versions restart are told apart.
+func versionETag(version int) string {
+	return `"` + etagPrefix + strconv.Itoa(version) + `"`
+}
+
+// Parses a strong ETag written by versionETag. ETags with another prefix
+// are unknown.
+func parseETag(tag string) (int, bool) {
+	if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+		return 0, false
+	}
+	number, ok := strings.CutPrefix(tag[1:len(tag)-1], etagPrefix)
+	if !ok {
+		return 0, false
+	}
+	version, err := strconv.Atoi(number)
+	return version, err == nil
+}
+
+// taskResponse writes a task with its ETag and Last-Modified headers
+func taskResponse(w http.ResponseWriter, task *Task, status int) {
+	w.Header().Set("ETag", taskETag(task))
+	w.Header().Set("Last-Modified", task.UpdatedAt.UTC().Format(http.TimeFormat))
+	jsonResponse(w, task, status)
+}
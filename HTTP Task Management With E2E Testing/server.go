@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// ServerConfig holds the listen address and timeouts of the HTTP server
+type ServerConfig struct {
+	Addr            string
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration
+}
+
+// RegisterServerFlags registers the flags of the server configuration. Their
+// defaults come from the ADDR, READ_TIMEOUT, WRITE_TIMEOUT, IDLE_TIMEOUT and
+// SHUTDOWN_TIMEOUT environment variables when they are set.
+func RegisterServerFlags(fs *flag.FlagSet) *ServerConfig {
+	config := &ServerConfig{}
+	fs.StringVar(&config.Addr, "addr", envString("ADDR", ":8080"), "address to listen on")
+	fs.DurationVar(&config.ReadTimeout, "read-timeout", envDuration("READ_TIMEOUT", 10*time.Second), "maximum time to read a request")
+	fs.DurationVar(&config.WriteTimeout, "write-timeout", envDuration("WRITE_TIMEOUT", 30*time.Second), "maximum time to write a response")
+	fs.DurationVar(&config.IdleTimeout, "idle-timeout", envDuration("IDLE_TIMEOUT", 2*time.Minute), "maximum time to keep an idle connection open")
+	fs.DurationVar(&config.ShutdownTimeout, "shutdown-timeout", envDuration("SHUTDOWN_TIMEOUT", 30*time.Second), "maximum time to wait for requests to finish when shutting down")
+	return config
+}
+
+// NewServer creates a new http.Server for the handler with the configured
+// address and timeouts
+func (c *ServerConfig) NewServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:         c.Addr,
+		Handler:      handler,
+		ReadTimeout:  c.ReadTimeout,
+		WriteTimeout: c.WriteTimeout,
+		IdleTimeout:  c.IdleTimeout,
+	}
+}
+
+// ListenAndServe serves the handler on the configured address until the
+// process receives an interrupt or SIGTERM, then shuts down gracefully.
+// onShutdown functions run when the shutdown starts, to end long-lived
+// requests that would otherwise hold it up.
+func (c *ServerConfig) ListenAndServe(handler http.Handler, onShutdown ...func()) error {
+	server := c.NewServer(handler)
+	for _, f := range onShutdown {
+		server.RegisterOnShutdown(f)
+	}
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Printf("Starting server on %s\n", listener.Addr())
+	return c.Serve(ctx, server, listener)
+}
+
+// Serve accepts connections on the listener until ctx is done, then stops
+// accepting new requests and waits up to the shutdown timeout for the
+// requests in flight to finish. Requests still running after the timeout
+// have their connections closed. Serve returns only once every handler has
+// returned, so the caller can release what the handlers use.
+func (c *ServerConfig) Serve(ctx context.Context, server *http.Server, listener net.Listener) error {
+	handlers := &handlerGroup{}
+	server.Handler = handlers.track(server.Handler)
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.Serve(listener)
+	}()
+
+	select {
+	case err := <-errs:
+		handlers.wait()
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("shutting down, waiting up to %s for requests to finish", c.ShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), c.ShutdownTimeout)
+	defer cancel()
+	err := server.Shutdown(shutdownCtx)
+	if err != nil {
+		// Closing the connections cancels the contexts of their requests
+		log.Printf("requests still running after %s, closing their connections", c.ShutdownTimeout)
+		server.Close()
+	}
+	handlers.wait()
+	if err != nil {
+		return err
+	}
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// handlerGroup counts the handlers that are running. Once it is waited on,
+// no new handler starts.
+type handlerGroup struct {
+	mu      sync.Mutex
+	stopped bool
+	running sync.WaitGroup
+}
+
+// Wraps a handler so that the group counts its runs
+func (g *handlerGroup) track(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		g.mu.Lock()
+		if g.stopped {
+			g.mu.Unlock()
+			httpError(w, r, http.StatusServiceUnavailable, "error.shutting_down")
+			return
+		}
+		g.running.Add(1)
+		g.mu.Unlock()
+		defer g.running.Done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Stops new handlers from starting and waits for the running ones to return
+func (g *handlerGroup) wait() {
+	g.mu.Lock()
+	g.stopped = true
+	g.mu.Unlock()
+	g.running.Wait()
+}
+
+// Returns the value of an environment variable, or fallback when it is unset
+func envString(name, fallback string) string {
+	if value, ok := os.LookupEnv(name); ok {
+		return value
+	}
+	return fallback
+}
+
+// Returns the duration in an environment variable, or fallback when it is
+// unset. An invalid duration stops the program.
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return fallback
+	}
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		log.Fatalf("invalid %s: %v", name, err)
+	}
+	return duration
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConditionalGet(t *testing.T) {
+	tm := NewTaskManager()
+	tm.AddTask("Write tests")
+	router := newRouter(tm)
+
+	get := func(path string, header http.Header) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header = header
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		return rr
+	}
+
+	for _, path := range []string{"/tasks", "/tasks/1"} {
+		rr := get(path, http.Header{})
+		etag, modified := rr.Header().Get("ETag"), rr.Header().Get("Last-Modified")
+		if rr.Code != http.StatusOK || etag == "" || modified == "" {
+			t.Fatalf("%s: expected a 200 with ETag and Last-Modified, got %d with %q and %q", path, rr.Code, etag, modified)
+		}
+
+		rr = get(path, http.Header{"If-None-Match": {`"other", W/` + etag}})
+		if rr.Code != http.StatusNotModified || rr.Body.Len() != 0 {
+			t.Errorf("%s: expected a 304 without a body for a matching ETag, got %d", path, rr.Code)
+		}
+		rr = get(path, http.Header{"If-Modified-Since": {modified}})
+		if rr.Code != http.StatusNotModified {
+			t.Errorf("%s: expected a 304 for an unchanged Last-Modified, got %d", path, rr.Code)
+		}
+		past := time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat)
+		rr = get(path, http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {modified}})
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected If-None-Match to take precedence, got %d", path, rr.Code)
+		}
+		rr = get(path, http.Header{"If-Modified-Since": {past}})
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected a 200 when modified since, got %d", path, rr.Code)
+		}
+	}
+
+	rr := get("/tasks", http.Header{})
+	listETag := rr.Header().Get("ETag")
+	// The revision numbers of an earlier run are not this run's
+	if rr := get("/tasks", http.Header{"If-None-Match": {strings.Replace(listETag, etagPrefix, "", 1)}}); rr.Code != http.StatusOK {
+		t.Errorf("expected an ETag of another run not to match, got %d", rr.Code)
+	}
+	req := httptest.NewRequest("PUT", "/tasks/1", strings.NewReader(`{"description": "Ship"}`))
+	router.ServeHTTP(httptest.NewRecorder(), req)
+	for _, path := range []string{"/tasks", "/tasks/1"} {
+		rr := get(path, http.Header{"If-None-Match": {listETag, versionETag(1)}})
+		if rr.Code != http.StatusOK {
+			t.Errorf("%s: expected a 200 after a write, got %d", path, rr.Code)
+		}
+	}
+}
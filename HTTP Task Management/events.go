@@ -0,0 +1,229 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of task events
+const (
+	TaskCreated = "created"
+	TaskUpdated = "updated"
+	TaskDeleted = "deleted"
+)
+
+const (
+	// Number of past events kept for clients that reconnect
+	eventHistorySize = 1000
+	// Number of events a slow client may fall behind before it is dropped
+	subscriberBuffer = 64
+	// Interval of the comments that keep idle streams open through proxies
+	heartbeatInterval = 15 * time.Second
+)
+
+// TaskEvent is a change to a task. Task is the task after the change, or nil
+// when it was deleted.
+type TaskEvent struct {
+	ID     string
+	Type   string
+	TaskID int
+	Task   *Task
+}
+
+// EventBroker keeps the recent task events and passes new ones to the
+// subscribers. Event IDs start with a token of the broker, so the IDs of an
+// earlier run of the server are recognised as unknown.
+type EventBroker struct {
+	mu          sync.Mutex
+	run         string
+	next        int
+	history     []TaskEvent
+	subscribers map[chan TaskEvent]bool
+	closed      bool
+	done        chan struct{}
+}
+
+// NewEventBroker creates a new EventBroker
+func NewEventBroker() *EventBroker {
+	return &EventBroker{run: newRunToken(), next: 1, subscribers: make(map[chan TaskEvent]bool), done: make(chan struct{})}
+}
+
+// newRunToken returns a random token that tells one run of the server from
+// another
+func newRunToken() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Publish records an event and sends it to every subscriber. Subscribers
+// that are too far behind are dropped; they resume with Last-Event-ID.
+func (b *EventBroker) Publish(eventType string, taskID int, task *Task) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if task != nil {
+		copied := *task
+		task = &copied
+	}
+	event := TaskEvent{ID: b.eventID(b.next), Type: eventType, TaskID: taskID, Task: task}
+	b.next++
+	b.history = append(b.history, event)
+	if len(b.history) > eventHistorySize {
+		b.history = b.history[len(b.history)-eventHistorySize:]
+	}
+
+	for events := range b.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(b.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+// Subscribe returns the events after lastID and a channel that receives the
+// events that follow. When lastID is not empty and the events after it are no
+// longer known, resetID is the ID of the latest event and the client has to
+// reload the tasks. The channel is closed when the subscriber is dropped or
+// the broker closes.
+func (b *EventBroker) Subscribe(lastID string) (missed []TaskEvent, events chan TaskEvent, resetID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	events = make(chan TaskEvent, subscriberBuffer)
+	if b.closed {
+		close(events)
+		return nil, events, ""
+	}
+	b.subscribers[events] = true
+
+	if lastID == "" {
+		return nil, events, ""
+	}
+	first := b.next - len(b.history)
+	sequence, ok := b.sequence(lastID)
+	if !ok || sequence < first-1 || sequence >= b.next {
+		return nil, events, b.eventID(b.next - 1)
+	}
+	missed = append(missed, b.history[sequence-first+1:]...)
+	return missed, events, ""
+}
+
+// Unsubscribe stops sending events to a channel returned by Subscribe
+func (b *EventBroker) Unsubscribe(events chan TaskEvent) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subscribers[events] {
+		delete(b.subscribers, events)
+		close(events)
+	}
+}
+
+// Close ends every subscription, so that open streams finish when the
+// server shuts down
+func (b *EventBroker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !b.closed {
+		close(b.done)
+	}
+	b.closed = true
+	for events := range b.subscribers {
+		delete(b.subscribers, events)
+		close(events)
+	}
+}
+
+// Done returns a channel that is closed when the broker closes
+func (b *EventBroker) Done() <-chan struct{} {
+	return b.done
+}
+
+func (b *EventBroker) eventID(sequence int) string {
+	return b.run + "-" + strconv.Itoa(sequence)
+}
+
+// Returns the sequence number of an event ID of this broker
+func (b *EventBroker) sequence(id string) (int, bool) {
+	run, number, ok := strings.Cut(id, "-")
+	if !ok || run != b.run {
+		return 0, false
+	}
+	sequence, err := strconv.Atoi(number)
+	return sequence, err == nil
+}
+
+// taskEventsHandler streams task events as Server-Sent Events. A client that
+// reconnects with a Last-Event-ID header, or a lastEventId query parameter,
+// first gets the events it missed.
+func taskEventsHandler(broker *EventBroker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lastID := r.Header.Get("Last-Event-ID")
+		if lastID == "" {
+			lastID = r.URL.Query().Get("lastEventId")
+		}
+		missed, events, resetID := broker.Subscribe(lastID)
+		defer broker.Unsubscribe(events)
+
+		// The stream outlives the write timeout of the server
+		controller := http.NewResponseController(w)
+		controller.SetWriteDeadline(time.Time{})
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+
+		if resetID != "" {
+			fmt.Fprintf(w, "id: %s\nevent: reset\ndata: {}\n\n", resetID)
+		}
+		for _, event := range missed {
+			writeTaskEvent(w, event)
+		}
+		if controller.Flush() != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				writeTaskEvent(w, event)
+			case <-heartbeat.C:
+				fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			if controller.Flush() != nil {
+				return
+			}
+		}
+	}
+}
+
+// Writes an event in the text/event-stream format. The data is the task, or
+// only its ID when it was deleted.
+func writeTaskEvent(w http.ResponseWriter, event TaskEvent) {
+	var data []byte
+	if event.Task != nil {
+		data, _ = json.Marshal(event.Task)
+	} else {
+		data, _ = json.Marshal(map[string]int{"id": event.TaskID})
+	}
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A Server-Sent Event as read by the tests
+type sseEvent struct {
+	id, event, data string
+}
+
+// Reads the next event of a stream, skipping comments
+func readEvent(t *testing.T, stream *bufio.Reader) sseEvent {
+	t.Helper()
+	var event sseEvent
+	for {
+		line, err := stream.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading event: %v", err)
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" && event != (sseEvent{}) {
+			return event
+		}
+		name, value, _ := strings.Cut(line, ": ")
+		switch name {
+		case "id":
+			event.id = value
+		case "event":
+			event.event = value
+		case "data":
+			event.data = value
+		}
+	}
+}
+
+// Opens the event stream of a test server
+func openEvents(t *testing.T, server *httptest.Server, lastEventID string) (*http.Response, *bufio.Reader) {
+	t.Helper()
+	req, err := http.NewRequest("GET", server.URL+"/tasks/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("expected an event stream, got %d with %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+	return resp, bufio.NewReader(resp.Body)
+}
+
+func TestTaskEventsStreamAndResume(t *testing.T) {
+	tm := NewTaskManager()
+	server := httptest.NewServer(newRouter(tm))
+	defer server.Close()
+
+	resp, stream := openEvents(t, server, "")
+	tm.AddTask("Write tests")
+	created := readEvent(t, stream)
+	if created.event != TaskCreated || !strings.Contains(created.data, `"description":"Write tests"`) {
+		t.Errorf("expected a created event with the task, got %+v", created)
+	}
+	resp.Body.Close()
+
+	tm.UpdateTask(1, "Ship", true, Precondition{})
+	tm.DeleteTask(1, Precondition{})
+
+	resp, stream = openEvents(t, server, created.id)
+	updated, deleted := readEvent(t, stream), readEvent(t, stream)
+	if updated.event != TaskUpdated || !strings.Contains(updated.data, `"description":"Ship"`) {
+		t.Errorf("expected the missed update first, got %+v", updated)
+	}
+	if deleted.event != TaskDeleted || deleted.data != `{"id":1}` {
+		t.Errorf("expected the missed delete next, got %+v", deleted)
+	}
+	resp.Body.Close()
+
+	resp, stream = openEvents(t, server, "unknown-5")
+	if reset := readEvent(t, stream); reset.event != "reset" || reset.id != deleted.id {
+		t.Errorf("expected a reset to the latest event for an unknown ID, got %+v", reset)
+	}
+
+	// Closing the broker ends open streams
+	tm.Events().Close()
+	if _, err := stream.ReadString('\n'); err == nil {
+		t.Error("expected the stream to end when the broker closes")
+	}
+	resp.Body.Close()
+}
+
+func TestSlowSubscribersAreDropped(t *testing.T) {
+	broker := NewEventBroker()
+	_, events, _ := broker.Subscribe("")
+	for i := 0; i <= subscriberBuffer; i++ {
+		broker.Publish(TaskCreated, i, &Task{ID: i})
+	}
+
+	received := 0
+	for range events {
+		received++
+	}
+	if received != subscriberBuffer {
+		t.Errorf("expected the %d buffered events before the channel closed, got %d", subscriberBuffer, received)
+	}
+	broker.Unsubscribe(events)
+}
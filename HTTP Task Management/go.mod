@@ -1,3 +1,5 @@
 module HTTP_Task_Management
 
 go 1.22
+
+require github.com/gorilla/websocket v1.5.3
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Locale identifies the language of the messages
+type Locale string
+
+// Supported locales
+const (
+	English Locale = "en"
+	Spanish Locale = "es"
+)
+
+// DefaultLocale is used when no supported locale is selected
+const DefaultLocale = English
+
+// message is a catalog entry. One is used when the plural rule of the locale
+// selects the singular form and Other for every other count.
+type message struct {
+	One   string
+	Other string
+}
+
+// pluralRules reports for each locale whether a count takes the singular form
+var pluralRules = map[Locale]func(n int) bool{
+	English: func(n int) bool { return n == 1 },
+	Spanish: func(n int) bool { return n == 1 },
+}
+
+// monthNames holds the month names used to format dates in each locale
+var monthNames = map[Locale][12]string{
+	English: {"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+	Spanish: {"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+}
+
+// Translator formats catalog messages for a locale
+type Translator struct {
+	locale Locale
+}
+
+// NewTranslator creates a new Translator, falling back to the default locale
+func NewTranslator(locale Locale) *Translator {
+	if _, ok := catalogs[locale]; !ok {
+		locale = DefaultLocale
+	}
+	return &Translator{locale: locale}
+}
+
+// Locale returns the locale of the translator
+func (t *Translator) Locale() Locale {
+	return t.locale
+}
+
+// T returns the message for key formatted with args
+func (t *Translator) T(key string, args ...interface{}) string {
+	return fmt.Sprintf(t.lookup(key).Other, args...)
+}
+
+// N returns the plural form of the message for key that matches count,
+// formatted with args
+func (t *Translator) N(key string, count int, args ...interface{}) string {
+	msg := t.lookup(key)
+	format := msg.Other
+	if pluralRules[t.locale](count) && msg.One != "" {
+		format = msg.One
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+// Bool returns the word for a boolean value
+func (t *Translator) Bool(value bool) string {
+	if value {
+		return t.T("bool.true")
+	}
+	return t.T("bool.false")
+}
+
+// Date formats a date and time the way the locale writes it
+func (t *Translator) Date(date time.Time) string {
+	month := monthNames[t.locale][date.Month()-1]
+	switch t.locale {
+	case Spanish:
+		return fmt.Sprintf("%d de %s de %d %s", date.Day(), month, date.Year(), date.Format("15:04"))
+	default:
+		return fmt.Sprintf("%s %d, %d %s", month, date.Day(), date.Year(), date.Format("3:04 PM"))
+	}
+}
+
+// Finds a message in the catalog of the locale, then in the default catalog
+func (t *Translator) lookup(key string) message {
+	if msg, ok := catalogs[t.locale][key]; ok {
+		return msg
+	}
+	if msg, ok := catalogs[DefaultLocale][key]; ok {
+		return msg
+	}
+	return message{Other: key}
+}
+
+// ParseLocale returns the supported locale of a language tag such as
+// "es", "es-ES" or "es_ES.UTF-8"
+func ParseLocale(tag string) (Locale, bool) {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	if i := strings.IndexAny(tag, "-_.@"); i >= 0 {
+		tag = tag[:i]
+	}
+	locale := Locale(tag)
+	_, ok := catalogs[locale]
+	return locale, ok
+}
+
+// SelectLocale returns the locale chosen by a flag value, then by the
+// LC_ALL, LC_MESSAGES and LANG environment variables, then the default
+func SelectLocale(flagValue string) Locale {
+	candidates := []string{flagValue, os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG")}
+	for _, candidate := range candidates {
+		if locale, ok := ParseLocale(candidate); ok {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
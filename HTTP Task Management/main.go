@@ -1,11 +1,15 @@
 package main
 
 import (
+	"bytes"
+	"cmp"
 	"encoding/json"
-	"fmt"
+	"errors"
+	"flag"
+	"io"
 	"log"
 	"net/http"
-	"strconv"
+	"sort"
 	"sync"
 	"time"
 )
@@ -16,18 +20,36 @@ type Task struct {
 	Description string    `json:"description"`
 	Completed   bool      `json:"completed"`
 	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Version     int       `json:"version"`
 }
 
-// TaskManager struct
+// ErrTaskNotFound is returned when a write targets a task that does not exist
+var ErrTaskNotFound = errors.New("task not found")
+
+// etagPrefix starts the ETags of tasks and of the task list. Versions and
+// revisions start again at 1 when the server restarts, so the prefix is a
+// token of the run and the ETags of an earlier run never match.
+var etagPrefix = newRunToken() + "-"
+
+// TaskManager struct. With RequirePreconditions set, writes to existing tasks
+// fail unless they carry a precondition.
 type TaskManager struct {
-	tasks map[int]*Task
-	mu    sync.Mutex
+	tasks                map[int]*Task
+	nextID               int
+	revision             Revision
+	events               *EventBroker
+	mu                   sync.Mutex
+	RequirePreconditions bool
 }
 
 // NewTaskManager creates a new TaskManager
 func NewTaskManager() *TaskManager {
 	return &TaskManager{
-		tasks: make(map[int]*Task),
+		tasks:    make(map[int]*Task),
+		nextID:   1,
+		revision: Revision{UpdatedAt: time.Now()},
+		events:   NewEventBroker(),
 	}
 }
 
@@ -36,16 +58,23 @@ func (tm *TaskManager) AddTask(description string) *Task {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
-	id := len(tm.tasks) + 1
+	// IDs are never reused, so a deleted task's ID cannot name another task
+	id := tm.nextID
+	tm.nextID++
+	now := time.Now()
 	task := &Task{
 		ID:          id,
 		Description: description,
 		Completed:   false,
-		CreatedAt:   time.Now(),
+		CreatedAt:   now,
+		UpdatedAt:   now,
+		Version:     1,
 	}
 	tm.tasks[id] = task
+	tm.changed(now)
+	tm.events.Publish(TaskCreated, id, task)
 
-	return task
+	return copyTask(task)
 }
 
 // GetTask gets a task by ID
@@ -54,121 +83,383 @@ func (tm *TaskManager) GetTask(id int) (*Task, bool) {
 	defer tm.mu.Unlock()
 
 	task, exists := tm.tasks[id]
-	return task, exists
+	if !exists {
+		return nil, false
+	}
+	return copyTask(task), true
 }
 
-// UpdateTask updates a task by ID
-func (tm *TaskManager) UpdateTask(id int, description string, completed bool) (*Task, bool) {
+// UpdateTask updates a task by ID if the precondition allows its version
+func (tm *TaskManager) UpdateTask(id int, description string, completed bool, precondition Precondition) (*Task, error) {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
-	task, exists := tm.tasks[id]
-	if exists {
-		task.Description = description
-		task.Completed = completed
+	task, err := tm.writableTask(id, precondition)
+	if err != nil {
+		return nil, err
+	}
+	task.Description = description
+	task.Completed = completed
+	tm.touch(task)
+	tm.events.Publish(TaskUpdated, id, task)
+	return copyTask(task), nil
+}
+
+// ApplyPatch applies a merge patch or JSON Patch of the given content type to
+// a task by ID if the precondition allows its version. The version only
+// changes when a field does. The patch is applied under the lock, so no other
+// write can come between reading the task and writing the changes. Errors of
+// the patch itself are *invalidPatchError.
+func (tm *TaskManager) ApplyPatch(id int, contentType string, patch []byte, precondition Precondition) (*Task, error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	task, err := tm.writableTask(id, precondition)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := patchTask(task, contentType, bytes.NewReader(patch))
+	if err != nil {
+		return nil, &invalidPatchError{err}
+	}
+	return tm.writeChanges(task, changes), nil
+}
+
+// Writes the changed fields of a task and returns a copy of it. The version
+// only changes when a field does. The caller holds the lock.
+func (tm *TaskManager) writeChanges(task *Task, changes TaskChanges) *Task {
+	if changes.Empty() {
+		return copyTask(task)
+	}
+	if changes.Description != nil {
+		task.Description = *changes.Description
+	}
+	if changes.Completed != nil {
+		task.Completed = *changes.Completed
 	}
-	return task, exists
+	tm.touch(task)
+	tm.events.Publish(TaskUpdated, task.ID, task)
+	return copyTask(task)
 }
 
-// DeleteTask deletes a task by ID
-func (tm *TaskManager) DeleteTask(id int) bool {
+// DeleteTask deletes a task by ID if the precondition allows its version
+func (tm *TaskManager) DeleteTask(id int, precondition Precondition) error {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
-	_, exists := tm.tasks[id]
-	if exists {
-		delete(tm.tasks, id)
+	if _, err := tm.writableTask(id, precondition); err != nil {
+		return err
 	}
-	return exists
+	delete(tm.tasks, id)
+	tm.changed(time.Now())
+	tm.events.Publish(TaskDeleted, id, nil)
+	return nil
+}
+
+// Applies a create, update, patch or delete message of the task WebSocket and
+// returns the task it wrote, or nil for a delete
+func (tm *TaskManager) applyMutation(message wsMessage) (*Task, error) {
+	switch message.Type {
+	case wsCreate:
+		task, err := messageTask(message)
+		if err != nil {
+			return nil, err
+		}
+		return tm.AddTask(task.Description), nil
+	case wsUpdate:
+		task, err := messageTask(message)
+		if err != nil {
+			return nil, err
+		}
+		return tm.UpdateTask(message.TaskID, task.Description, task.Completed, messagePrecondition(message))
+	case wsPatch:
+		return tm.ApplyPatch(message.TaskID, messagePatchType(message), message.Patch, messagePrecondition(message))
+	case wsDelete:
+		return nil, tm.DeleteTask(message.TaskID, messagePrecondition(message))
+	}
+	return nil, errUnknownMessage
+}
+
+// Events returns the broker of the task events
+func (tm *TaskManager) Events() *EventBroker {
+	return tm.events
+}
+
+// Revision returns the current revision of the task collection
+func (tm *TaskManager) Revision() Revision {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	return tm.revision
+}
+
+// Returns a copy of a stored task, which the caller may read after the lock
+// is released while later writes change the stored one
+func copyTask(task *Task) *Task {
+	copied := *task
+	return &copied
 }
 
-// ListTasks lists all tasks
+// Records a write to a task; the caller holds the lock
+func (tm *TaskManager) touch(task *Task) {
+	task.UpdatedAt = time.Now()
+	task.Version++
+	tm.changed(task.UpdatedAt)
+}
+
+// Moves the collection to a new revision; the caller holds the lock
+func (tm *TaskManager) changed(at time.Time) {
+	tm.revision = Revision{Number: tm.revision.Number + 1, UpdatedAt: at}
+}
+
+// Returns the task a write targets after checking the precondition; the
+// caller holds the lock
+func (tm *TaskManager) writableTask(id int, precondition Precondition) (*Task, error) {
+	task, exists := tm.tasks[id]
+	if !exists {
+		return nil, ErrTaskNotFound
+	}
+	if err := precondition.Check(task.Version, tm.RequirePreconditions); err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
+// ListTasks lists all tasks ordered by ID
 func (tm *TaskManager) ListTasks() []*Task {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
 
 	var tasks []*Task
 	for _, task := range tm.tasks {
-		tasks = append(tasks, task)
+		tasks = append(tasks, copyTask(task))
 	}
+	sort.Slice(tasks, func(i, j int) bool { return tasks[i].ID < tasks[j].ID })
 	return tasks
 }
 
+// QueryTasks returns the page of tasks selected by a query
+func (tm *TaskManager) QueryTasks(q TaskQuery) (TaskPage, error) {
+	var key interface{}
+	if q.Cursor != nil {
+		var err error
+		if key, err = q.Cursor.key(q.Sort); err != nil {
+			return TaskPage{}, err
+		}
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	var matching []*Task
+	for _, task := range tm.tasks {
+		if q.Completed == nil || task.Completed == *q.Completed {
+			matching = append(matching, copyTask(task))
+		}
+	}
+	sort.Slice(matching, func(i, j int) bool {
+		return compareTask(q, matching[i], taskSortValue(matching[j], q.Sort), matching[j].ID) < 0
+	})
+
+	// Seek to the cursor in the sorted tasks
+	window := matching
+	if q.Cursor != nil {
+		if q.Cursor.Before {
+			end := sort.Search(len(matching), func(i int) bool { return compareTask(q, matching[i], key, q.Cursor.ID) >= 0 })
+			window = matching[:end]
+		} else {
+			start := sort.Search(len(matching), func(i int) bool { return compareTask(q, matching[i], key, q.Cursor.ID) > 0 })
+			window = matching[start:]
+		}
+	}
+	more := len(window) > q.Limit
+	if more && q.Cursor != nil && q.Cursor.Before {
+		window = window[len(window)-q.Limit:]
+	} else if more {
+		window = window[:q.Limit]
+	}
+	return q.page(window, more, len(matching)), nil
+}
+
+// Compares a task with a position given by a sort value and an ID in the
+// order of a query
+func compareTask(q TaskQuery, task *Task, key interface{}, id int) int {
+	result := compareValues(taskSortValue(task, q.Sort), key)
+	if result == 0 {
+		result = compareValues(task.ID, id)
+	}
+	if q.Descending {
+		return -result
+	}
+	return result
+}
+
+// Compares two sort values of the same type
+func compareValues(a, b interface{}) int {
+	switch a := a.(type) {
+	case int:
+		return cmp.Compare(a, b.(int))
+	case string:
+		return cmp.Compare(a, b.(string))
+	case bool:
+		if a == b.(bool) {
+			return 0
+		} else if a {
+			return 1
+		}
+		return -1
+	case time.Time:
+		return a.Compare(b.(time.Time))
+	}
+	return 0
+}
+
 // Middleware for logging requests
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.Method, r.RequestURI, r.RemoteAddr)
+		log.Printf("%s %s %s %s", requestID(r), r.Method, r.RequestURI, r.RemoteAddr)
 		next.ServeHTTP(w, r)
 	})
 }
 
 func main() {
+	lang := flag.String("lang", "", "default language of the error messages (en or es); defaults to LC_ALL, LC_MESSAGES or LANG")
+	requireIfMatch := flag.Bool("require-if-match", false, "reject task writes without an If-Match header")
+	serverConfig := RegisterServerFlags(flag.CommandLine)
+	flag.Parse()
+	serverLocale = SelectLocale(*lang)
+
 	tm := NewTaskManager()
+	tm.RequirePreconditions = *requireIfMatch
+
+	// Wrap the router with the request ID and logging middleware
+	loggedRouter := requestIDMiddleware(loggingMiddleware(newRouter(tm)))
+
+	if err := serverConfig.ListenAndServe(loggedRouter, tm.Events().Close); err != nil {
+		log.Fatalf("server stopped: %v\n", err)
+	}
+}
+
+// newRouter declares the routes of the task API
+func newRouter(tm *TaskManager) *Router {
+	router := NewRouter()
+
+	router.HandleFunc("GET /tasks", func(w http.ResponseWriter, r *http.Request) {
+		query, err := ParseTaskQuery(r.URL.Query())
+		if err != nil {
+			invalidRequest(w, r, http.StatusBadRequest, "error.invalid_query", err)
+			return
+		}
+		revision := tm.Revision()
+		if notModified(w, r, revision.ETag(), revision.UpdatedAt) {
+			return
+		}
+		page, err := tm.QueryTasks(query)
+		if err != nil {
+			internalError(w, r, err)
+			return
+		}
+		setPageHeaders(w, r, page)
+		jsonResponse(w, page.Tasks, http.StatusOK)
+	})
+
+	router.HandleFunc("GET /tasks/events", taskEventsHandler(tm.Events()))
+
+	router.HandleFunc("GET /tasks/ws", taskSocketHandler(tm))
+
+	router.HandleFunc("POST /tasks", func(w http.ResponseWriter, r *http.Request) {
+		var task Task
+		if !decodeBody(w, r, &task) {
+			return
+		}
+		if err := validateTask(&task); err != nil {
+			invalidRequest(w, r, http.StatusUnprocessableEntity, "error.invalid_task", err)
+			return
+		}
+		newTask := tm.AddTask(task.Description)
+		taskResponse(w, newTask, http.StatusCreated)
+	})
+
+	router.HandleFunc("GET /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
+			return
+		}
+		task, exists := tm.GetTask(id)
+		if !exists {
+			httpError(w, r, http.StatusNotFound, "error.task_not_found")
+			return
+		}
+		if notModified(w, r, taskETag(task), task.UpdatedAt) {
+			return
+		}
+		taskResponse(w, task, http.StatusOK)
+	})
 
-	http.HandleFunc("/tasks", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case "GET":
-			tasks := tm.ListTasks()
-			jsonResponse(w, tasks, http.StatusOK)
-		case "POST":
-			var task Task
-			if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			newTask := tm.AddTask(task.Description)
-			jsonResponse(w, newTask, http.StatusCreated)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	router.HandleFunc("PUT /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
+			return
+		}
+		var task Task
+		if !decodeBody(w, r, &task) {
+			return
 		}
+		if err := validateTask(&task); err != nil {
+			invalidRequest(w, r, http.StatusUnprocessableEntity, "error.invalid_task", err)
+			return
+		}
+		updatedTask, err := tm.UpdateTask(id, task.Description, task.Completed, ParseIfMatch(r))
+		if err != nil {
+			taskError(w, r, err)
+			return
+		}
+		taskResponse(w, updatedTask, http.StatusOK)
 	})
 
-	http.HandleFunc("/tasks/", func(w http.ResponseWriter, r *http.Request) {
-		idStr := r.URL.Path[len("/tasks/"):]
-		id, err := strconv.Atoi(idStr)
+	router.HandleFunc("PATCH /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
 		if err != nil {
-			http.Error(w, "Invalid task ID", http.StatusBadRequest)
-			return
-		}
-
-		switch r.Method {
-		case "GET":
-			task, exists := tm.GetTask(id)
-			if !exists {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			jsonResponse(w, task, http.StatusOK)
-		case "PUT":
-			var task Task
-			if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
-				http.Error(w, err.Error(), http.StatusBadRequest)
-				return
-			}
-			updatedTask, exists := tm.UpdateTask(id, task.Description, task.Completed)
-			if !exists {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			jsonResponse(w, updatedTask, http.StatusOK)
-		case "DELETE":
-			if !tm.DeleteTask(id) {
-				http.Error(w, "Task not found", http.StatusNotFound)
-				return
-			}
-			w.WriteHeader(http.StatusNoContent)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
+			return
 		}
+		// The body is read before the store is locked, so a slow client
+		// cannot hold up other writes
+		patch, err := io.ReadAll(limitBody(w, r))
+		if err != nil {
+			patchError(w, r, err)
+			return
+		}
+		patchedTask, err := tm.ApplyPatch(id, r.Header.Get("Content-Type"), patch, ParseIfMatch(r))
+		var invalid *invalidPatchError
+		if errors.As(err, &invalid) {
+			patchError(w, r, invalid.err)
+			return
+		} else if err != nil {
+			taskError(w, r, err)
+			return
+		}
+		taskResponse(w, patchedTask, http.StatusOK)
 	})
 
-	// Wrap the default mux with the logging middleware
-	loggedMux := loggingMiddleware(http.DefaultServeMux)
+	router.HandleFunc("DELETE /tasks/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id, err := PathInt(r, "id")
+		if err != nil {
+			httpError(w, r, http.StatusBadRequest, "error.invalid_task_id")
+			return
+		}
+		if err := tm.DeleteTask(id, ParseIfMatch(r)); err != nil {
+			taskError(w, r, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
 
-	fmt.Println("Starting server on :8080")
-	if err := http.ListenAndServe(":8080", loggedMux); err != nil {
-		log.Fatalf("could not start server: %v\n", err)
-	}
+	registerDocs(router)
+
+	return router
 }
 
 // jsonResponse encodes response as JSON and writes it to the ResponseWriter
@@ -176,6 +467,20 @@ func jsonResponse(w http.ResponseWriter, data interface{}, status int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	if err := json.NewEncoder(w).Encode(data); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		log.Printf("could not write response: %v", err)
+	}
+}
+
+// taskError replies to a failed write of a task
+func taskError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrTaskNotFound):
+		httpError(w, r, http.StatusNotFound, "error.task_not_found")
+	case errors.Is(err, ErrVersionMismatch):
+		httpError(w, r, http.StatusPreconditionFailed, "error.version_mismatch")
+	case errors.Is(err, ErrPreconditionRequired):
+		httpError(w, r, http.StatusPreconditionRequired, "error.precondition_required")
+	default:
+		internalError(w, r, err)
 	}
 }
@@ -0,0 +1,80 @@
+package main
+
+// serverLocale is used for clients that accept no supported language
+var serverLocale = DefaultLocale
+
+// catalogs holds the messages of every supported locale
+var catalogs = map[Locale]map[string]message{
+	English: {
+		"bool.true":                   {Other: "true"},
+		"bool.false":                  {Other: "false"},
+		"error.method_not_allowed":    {Other: "Method not allowed"},
+		"error.invalid_task_id":       {Other: "Invalid task ID"},
+		"error.task_not_found":        {Other: "Task not found"},
+		"error.invalid_body":          {Other: "The request body is not valid JSON for this resource"},
+		"error.body_too_large":        {Other: "The request body is larger than %d bytes"},
+		"error.not_found":             {Other: "No resource at %s"},
+		"error.unsupported_patch":     {Other: "Unsupported patch format %q; use application/merge-patch+json or application/json-patch+json"},
+		"error.invalid_patch":         {Other: "Invalid patch: operation %d: %s"},
+		"error.patch_test_failed":     {Other: "Patch test operation failed"},
+		"error.version_mismatch":      {Other: "The task has changed since it was read; fetch it again and retry with its new ETag"},
+		"error.precondition_required": {Other: "This request needs an If-Match header with the ETag of the task"},
+		"error.invalid_task":          {Other: "The task is not valid"},
+		"error.invalid_query":         {Other: "The query parameters are not valid"},
+		"error.invalid_message":       {Other: "The message is not one the task WebSocket understands"},
+		"error.internal":              {Other: "An internal error occurred; quote the request ID when reporting it"},
+		"error.shutting_down":         {Other: "The server is shutting down; retry the request shortly"},
+		"field.required":              {Other: "is required"},
+		"field.boolean":               {Other: "must be true or false"},
+		"field.string":                {Other: "must be a string"},
+		"field.number":                {Other: "must be a number"},
+		"field.unknown":               {Other: "is not a known field"},
+		"field.read_only":             {Other: "cannot be changed"},
+		"field.sort":                  {Other: "must be id, description, completed or created_at, optionally with a leading -"},
+		"field.range":                 {Other: "must be between %d and %d"},
+		"field.invalid":               {Other: "is not valid"},
+		"patch.needs_value":           {Other: "%s needs a value"},
+		"patch.invalid_value":         {Other: "the value is not valid JSON"},
+		"patch.move_into_itself":      {Other: "cannot move a value into itself"},
+		"patch.unknown_op":            {Other: "unknown op %q"},
+		"patch.invalid_pointer":       {Other: "invalid pointer %q"},
+		"patch.path_missing":          {Other: "path %q does not exist"},
+		"patch.invalid_index":         {Other: "invalid array index %q"},
+	},
+	Spanish: {
+		"bool.true":                   {Other: "sí"},
+		"bool.false":                  {Other: "no"},
+		"error.method_not_allowed":    {Other: "Método no permitido"},
+		"error.invalid_task_id":       {Other: "ID de tarea no válido"},
+		"error.task_not_found":        {Other: "Tarea no encontrada"},
+		"error.invalid_body":          {Other: "El cuerpo de la solicitud no es un JSON válido para este recurso"},
+		"error.body_too_large":        {Other: "El cuerpo de la solicitud ocupa más de %d bytes"},
+		"error.not_found":             {Other: "No hay ningún recurso en %s"},
+		"error.unsupported_patch":     {Other: "Formato de parche %q no admitido; use application/merge-patch+json o application/json-patch+json"},
+		"error.invalid_patch":         {Other: "Parche no válido: operación %d: %s"},
+		"error.patch_test_failed":     {Other: "La operación test del parche falló"},
+		"error.version_mismatch":      {Other: "La tarea ha cambiado desde que se leyó; vuelva a obtenerla y reintente con su nuevo ETag"},
+		"error.precondition_required": {Other: "Esta solicitud necesita un encabezado If-Match con el ETag de la tarea"},
+		"error.invalid_task":          {Other: "La tarea no es válida"},
+		"error.invalid_query":         {Other: "Los parámetros de la consulta no son válidos"},
+		"error.invalid_message":       {Other: "El mensaje no es uno que entienda el WebSocket de tareas"},
+		"error.internal":              {Other: "Se produjo un error interno; indique el ID de la solicitud al informarlo"},
+		"error.shutting_down":         {Other: "El servidor se está deteniendo; vuelva a intentarlo en breve"},
+		"field.required":              {Other: "es obligatorio"},
+		"field.boolean":               {Other: "debe ser true o false"},
+		"field.string":                {Other: "debe ser una cadena"},
+		"field.number":                {Other: "debe ser un número"},
+		"field.unknown":               {Other: "no es un campo conocido"},
+		"field.read_only":             {Other: "no se puede modificar"},
+		"field.sort":                  {Other: "debe ser id, description, completed o created_at, con un - delante opcional"},
+		"field.range":                 {Other: "debe estar entre %d y %d"},
+		"field.invalid":               {Other: "no es válido"},
+		"patch.needs_value":           {Other: "%s necesita un valor"},
+		"patch.invalid_value":         {Other: "el valor no es JSON válido"},
+		"patch.move_into_itself":      {Other: "no se puede mover un valor dentro de sí mismo"},
+		"patch.unknown_op":            {Other: "la operación %q no existe"},
+		"patch.invalid_pointer":       {Other: "el puntero %q no es válido"},
+		"patch.path_missing":          {Other: "la ruta %q no existe"},
+		"patch.invalid_index":         {Other: "el índice %q no es válido"},
+	},
+}
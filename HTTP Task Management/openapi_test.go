@@ -0,0 +1,74 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// The parts of an OpenAPI document the tests check
+type openAPIDocument struct {
+	OpenAPI    string                            `json:"openapi"`
+	Paths      map[string]map[string]interface{} `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]interface{} `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	var document openAPIDocument
+	if err := json.Unmarshal(openAPISpec, &document); err != nil {
+		t.Fatal(err)
+	}
+	if document.OpenAPI != "3.1.0" {
+		t.Errorf("expected OpenAPI 3.1.0, got %q", document.OpenAPI)
+	}
+
+	var documented []string
+	for path, item := range document.Paths {
+		for method := range item {
+			if method != "parameters" {
+				documented = append(documented, strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+	routes := newRouter(NewTaskManager()).Routes()
+	sort.Strings(documented)
+	sort.Strings(routes)
+	if !slices.Equal(documented, routes) {
+		t.Errorf("expected the document to describe the routes\n%v\ngot\n%v", routes, documented)
+	}
+
+	var fields []string
+	taskType := reflect.TypeOf(Task{})
+	for i := 0; i < taskType.NumField(); i++ {
+		fields = append(fields, strings.Split(taskType.Field(i).Tag.Get("json"), ",")[0])
+	}
+	var properties []string
+	for name := range document.Components.Schemas["Task"].Properties {
+		properties = append(properties, name)
+	}
+	sort.Strings(fields)
+	sort.Strings(properties)
+	if !slices.Equal(fields, properties) {
+		t.Errorf("expected the Task schema to have the fields %v, got %v", fields, properties)
+	}
+}
+
+func TestDocsAreServed(t *testing.T) {
+	router := newRouter(NewTaskManager())
+	for path, contentType := range map[string]string{"/openapi.json": "application/json", "/docs": "text/html; charset=utf-8"} {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+		if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != contentType {
+			t.Errorf("%s: expected a 200 with %s, got %d with %q", path, contentType, rr.Code, rr.Header().Get("Content-Type"))
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPatchTask(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		status      int
+		description string
+		completed   bool
+	}{
+		{"merge patch keeps other fields", mergePatchType, `{"completed": true}`, http.StatusOK, "Write tests", true},
+		{"json patch", jsonPatchType, `[{"op": "test", "path": "/completed", "value": false}, {"op": "replace", "path": "/description", "value": "Ship"}]`, http.StatusOK, "Ship", false},
+		{"failed test", jsonPatchType, `[{"op": "test", "path": "/completed", "value": true}, {"op": "replace", "path": "/completed", "value": true}]`, http.StatusConflict, "Write tests", false},
+		{"read-only field", mergePatchType, `{"id": 7}`, http.StatusUnprocessableEntity, "Write tests", false},
+		{"wrong type", jsonPatchType, `[{"op": "replace", "path": "/completed", "value": "yes"}]`, http.StatusUnprocessableEntity, "Write tests", false},
+		{"removed required field", mergePatchType, `{"description": null}`, http.StatusUnprocessableEntity, "Write tests", false},
+		{"unknown field", mergePatchType, `{"priority": 1}`, http.StatusUnprocessableEntity, "Write tests", false},
+		{"unsupported format", "text/plain", `completed`, http.StatusUnsupportedMediaType, "Write tests", false},
+		{"missing path", jsonPatchType, `[{"op": "remove", "path": "/priority"}]`, http.StatusBadRequest, "Write tests", false},
+		{"unknown op", jsonPatchType, `[{"op": "rename", "path": "/description"}]`, http.StatusBadRequest, "Write tests", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tm := NewTaskManager()
+			tm.AddTask("Write tests")
+			router := newRouter(tm)
+
+			req := httptest.NewRequest("PATCH", "/tasks/1", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", tt.contentType)
+			rr := httptest.NewRecorder()
+			router.ServeHTTP(rr, req)
+
+			if rr.Code != tt.status {
+				t.Fatalf("expected status %d, got %d: %s", tt.status, rr.Code, rr.Body.String())
+			}
+			task, _ := tm.GetTask(1)
+			if task.Description != tt.description || task.Completed != tt.completed {
+				t.Errorf("expected %q completed=%t, got %q completed=%t", tt.description, tt.completed, task.Description, task.Completed)
+			}
+			if rr.Code == http.StatusOK {
+				var patched Task
+				if err := json.NewDecoder(rr.Body).Decode(&patched); err != nil || patched.Description != tt.description {
+					t.Errorf("expected the patched task in the response, got %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestPatchErrorsAreLocalized(t *testing.T) {
+	tm := NewTaskManager()
+	tm.AddTask("Write tests")
+
+	req := httptest.NewRequest("PATCH", "/tasks/1", strings.NewReader(`[{"op": "test", "path": "/completed", "value": false}, {"op": "remove", "path": "/priority"}]`))
+	req.Header.Set("Content-Type", jsonPatchType)
+	req.Header.Set("Accept-Language", "es")
+	rr := httptest.NewRecorder()
+	newRouter(tm).ServeHTTP(rr, req)
+
+	var problem Problem
+	if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if want := `Parche no válido: operación 1: la ruta "/priority" no existe`; problem.Type != "/problems/invalid-patch" || problem.Detail != want {
+		t.Errorf("expected %q, got %+v", want, problem)
+	}
+}
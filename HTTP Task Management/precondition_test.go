@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWritesHonourIfMatch(t *testing.T) {
+	tm := NewTaskManager()
+	tm.AddTask("Write tests")
+	router := newRouter(tm)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/tasks/1", nil))
+	etag := rr.Header().Get("ETag")
+	if etag != versionETag(1) || !strings.HasPrefix(etag, `"`+etagPrefix) {
+		t.Fatalf("expected the ETag of version 1, got %q", etag)
+	}
+
+	steps := []struct {
+		method      string
+		contentType string
+		body        string
+		ifMatch     string
+		status      int
+		etag        string
+	}{
+		{"PUT", "application/json", `{"description": "Ship"}`, etag, http.StatusOK, versionETag(2)},
+		{"PUT", "application/json", `{"description": "Lost update"}`, etag, http.StatusPreconditionFailed, ""},
+		{"PATCH", mergePatchType, `{"completed": true}`, etag, http.StatusPreconditionFailed, ""},
+		{"PATCH", mergePatchType, `{"completed": true}`, "W/" + versionETag(2), http.StatusPreconditionFailed, ""},
+		{"PATCH", mergePatchType, `{"completed": true}`, `"2"`, http.StatusPreconditionFailed, ""},
+		{"PATCH", mergePatchType, `{"completed": true}`, versionETag(1) + ", " + versionETag(2), http.StatusOK, versionETag(3)},
+		{"PATCH", mergePatchType, `{"version": 9}`, "", http.StatusUnprocessableEntity, ""},
+		{"DELETE", "", "", etag, http.StatusPreconditionFailed, ""},
+		{"DELETE", "", "", "*", http.StatusNoContent, ""},
+	}
+	for _, step := range steps {
+		req := httptest.NewRequest(step.method, "/tasks/1", strings.NewReader(step.body))
+		req.Header.Set("Content-Type", step.contentType)
+		if step.ifMatch != "" {
+			req.Header.Set("If-Match", step.ifMatch)
+		}
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+		if rr.Code != step.status || rr.Header().Get("ETag") != step.etag {
+			t.Errorf("%s with If-Match %s: expected status %d and ETag %q, got %d and %q",
+				step.method, step.ifMatch, step.status, step.etag, rr.Code, rr.Header().Get("ETag"))
+		}
+	}
+}
+
+func TestStoreCanRequirePreconditions(t *testing.T) {
+	tm := NewTaskManager()
+	tm.RequirePreconditions = true
+	tm.AddTask("Write tests")
+
+	if _, err := tm.UpdateTask(1, "Ship", false, Precondition{}); err != ErrPreconditionRequired {
+		t.Errorf("expected ErrPreconditionRequired without a precondition, got %v", err)
+	}
+	if _, err := tm.UpdateTask(1, "Ship", false, IfVersion(1)); err != nil {
+		t.Errorf("expected the update to succeed with the current version, got %v", err)
+	}
+
+	req := httptest.NewRequest("DELETE", "/tasks/1", nil)
+	rr := httptest.NewRecorder()
+	newRouter(tm).ServeHTTP(rr, req)
+	if rr.Code != http.StatusPreconditionRequired {
+		t.Errorf("expected status %d, got %d", http.StatusPreconditionRequired, rr.Code)
+	}
+}
+
+func TestDeletedTaskIDsAreNotReused(t *testing.T) {
+	tm := NewTaskManager()
+	tm.AddTask("Write tests")
+	second := tm.AddTask("Ship")
+	if err := tm.DeleteTask(second.ID, Precondition{}); err != nil {
+		t.Fatal(err)
+	}
+	if third := tm.AddTask("Celebrate"); third.ID != 3 {
+		t.Errorf("expected a new ID after a delete, got %d", third.ID)
+	}
+	if _, exists := tm.GetTask(second.ID); exists {
+		t.Error("expected the deleted ID to stay free")
+	}
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+)
+
+// problemContentType is the media type of error responses (RFC 7807)
+const problemContentType = "application/problem+json"
+
+// Problem is the body of an error response
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError explains why one field of a request is not valid
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// fieldError is a validation failure of a field, described by a message key
+// that is translated when the response is written
+type fieldError struct {
+	field string
+	key   string
+	args  []interface{}
+}
+
+func (e *fieldError) Error() string {
+	return e.field + " " + NewTranslator(DefaultLocale).T(e.key, e.args...)
+}
+
+type requestIDKey struct{}
+
+// requestIDPattern matches the request IDs accepted from clients
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Middleware that gives every request an ID, taken from a valid X-Request-ID
+// header or generated, and echoes it in the response
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			id = newRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+// requestID returns the ID of a request, empty outside requestIDMiddleware
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// httpError replies to the request with a problem whose detail is the
+// message for key in the language accepted by the client
+func httpError(w http.ResponseWriter, r *http.Request, status int, key string, args ...interface{}) {
+	writeProblem(w, r, status, key, args, nil)
+}
+
+// validationError replies to the request with a problem that lists the
+// fields that are not valid
+func validationError(w http.ResponseWriter, r *http.Request, status int, key string, errs ...*fieldError) {
+	writeProblem(w, r, status, key, nil, errs)
+}
+
+// invalidRequest replies to the request with a problem for a request error.
+// Field errors are listed in the problem; other errors are described by key.
+func invalidRequest(w http.ResponseWriter, r *http.Request, status int, key string, err error) {
+	var fieldErr *fieldError
+	if errors.As(err, &fieldErr) {
+		validationError(w, r, status, key, fieldErr)
+		return
+	}
+	httpError(w, r, status, key)
+}
+
+// internalError logs an unexpected error and replies with a problem that
+// does not reveal it
+func internalError(w http.ResponseWriter, r *http.Request, err error) {
+	log.Printf("request %s: %s %s: %v", requestID(r), r.Method, r.URL.Path, err)
+	httpError(w, r, http.StatusInternalServerError, "error.internal")
+}
+
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, key string, args []interface{}, errs []*fieldError) {
+	tr := translatorFor(r)
+	problem := newProblem(r, tr, status, key, args, errs)
+
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("Content-Language", string(tr.Locale()))
+	w.Header().Add("Vary", "Accept-Language")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(problem); err != nil {
+		log.Printf("request %s: could not write problem: %v", requestID(r), err)
+	}
+}
+
+// newProblem describes an error of a request in the language of tr
+func newProblem(r *http.Request, tr *Translator, status int, key string, args []interface{}, errs []*fieldError) Problem {
+	problem := Problem{
+		Type:      "/problems/" + strings.ReplaceAll(strings.TrimPrefix(key, "error."), "_", "-"),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    tr.T(key, args...),
+		Instance:  r.URL.Path,
+		RequestID: requestID(r),
+	}
+	for _, err := range errs {
+		problem.Errors = append(problem.Errors, FieldError{Field: err.field, Message: tr.T(err.key, err.args...)})
+	}
+	return problem
+}
+
+// MaxBodySize is the largest request body, in bytes, a handler reads
+const MaxBodySize = 1 << 20
+
+// limitBody returns the body of a request limited to MaxBodySize bytes.
+// Reading past the limit fails with an *http.MaxBytesError.
+func limitBody(w http.ResponseWriter, r *http.Request) io.Reader {
+	return http.MaxBytesReader(w, r.Body, MaxBodySize)
+}
+
+// bodyTooLarge reports whether err comes from reading a body past its limit,
+// and if so replies with a 413 problem
+func bodyTooLarge(w http.ResponseWriter, r *http.Request, err error) bool {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return false
+	}
+	httpError(w, r, http.StatusRequestEntityTooLarge, "error.body_too_large", tooLarge.Limit)
+	return true
+}
+
+// decodeBody decodes the JSON body of a request, up to MaxBodySize bytes,
+// into v. When it fails it replies with a problem and returns false.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(limitBody(w, r)).Decode(v)
+	if err == nil {
+		return true
+	}
+	if bodyTooLarge(w, r, err) {
+		return false
+	}
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		validationError(w, r, http.StatusBadRequest, "error.invalid_body", typeFieldError(typeErr))
+		return false
+	}
+	httpError(w, r, http.StatusBadRequest, "error.invalid_body")
+	return false
+}
+
+// Describes the error of decoding a JSON value into a struct
+func decodeFieldError(err error) *fieldError {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return typeFieldError(typeErr)
+	}
+	if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		return &fieldError{field: strings.Trim(field, `"`), key: "field.unknown"}
+	}
+	return &fieldError{key: "field.invalid"}
+}
+
+// Describes a JSON value of the wrong type
+func typeFieldError(err *json.UnmarshalTypeError) *fieldError {
+	switch err.Type.Kind() {
+	case reflect.Bool:
+		return &fieldError{field: err.Field, key: "field.boolean"}
+	case reflect.String:
+		return &fieldError{field: err.Field, key: "field.string"}
+	case reflect.Int, reflect.Int64, reflect.Float64:
+		return &fieldError{field: err.Field, key: "field.number"}
+	default:
+		return &fieldError{field: err.Field, key: "field.invalid"}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestErrorsAreProblems(t *testing.T) {
+	handler := requestIDMiddleware(newRouter(NewTaskManager()))
+
+	req := httptest.NewRequest("POST", "/tasks", strings.NewReader(`{"description": "", "completed": "yes"}`))
+	req.Header.Set("X-Request-ID", "abc-123")
+	req.Header.Set("Accept-Language", "es")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusBadRequest || rr.Header().Get("Content-Type") != problemContentType {
+		t.Fatalf("expected a %d problem, got %d %q", http.StatusBadRequest, rr.Code, rr.Header().Get("Content-Type"))
+	}
+	var problem Problem
+	if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+		t.Fatal(err)
+	}
+	if problem.Status != http.StatusBadRequest || problem.Type != "/problems/invalid-body" || problem.RequestID != "abc-123" {
+		t.Errorf("unexpected problem: %+v", problem)
+	}
+	if len(problem.Errors) != 1 || problem.Errors[0].Field != "completed" || problem.Errors[0].Message != "debe ser true o false" {
+		t.Errorf("expected a localized error for the completed field, got %+v", problem.Errors)
+	}
+}
+
+func TestInternalErrorsAreNotExposed(t *testing.T) {
+	var logged strings.Builder
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+
+	handler := requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		internalError(w, r, errors.New("database is locked"))
+	}))
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("GET", "/tasks", nil))
+
+	if rr.Code != http.StatusInternalServerError || strings.Contains(rr.Body.String(), "database is locked") {
+		t.Errorf("expected a 500 without the cause, got %d %s", rr.Code, rr.Body.String())
+	}
+	id := rr.Header().Get("X-Request-ID")
+	if id == "" || !strings.Contains(logged.String(), id) || !strings.Contains(logged.String(), "database is locked") {
+		t.Errorf("expected the cause to be logged with request ID %q, got %q", id, logged.String())
+	}
+}
+
+func TestLargeBodiesAreRejected(t *testing.T) {
+	tm := NewTaskManager()
+	tm.AddTask("Write tests")
+	handler := newRouter(tm)
+	large := `{"description": "` + strings.Repeat("x", MaxBodySize) + `"}`
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest("POST", "/tasks", strings.NewReader(large)),
+		httptest.NewRequest("PUT", "/tasks/1", strings.NewReader(large)),
+		httptest.NewRequest("PATCH", "/tasks/1", strings.NewReader(large)),
+	} {
+		req.Header.Set("Content-Type", "application/merge-patch+json")
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+
+		var problem Problem
+		if err := json.NewDecoder(rr.Body).Decode(&problem); err != nil {
+			t.Fatal(err)
+		}
+		if rr.Code != http.StatusRequestEntityTooLarge || problem.Type != "/problems/body-too-large" {
+			t.Errorf("%s: expected a 413 problem, got %d %+v", req.Method, rr.Code, problem)
+		}
+	}
+	if task, _ := tm.GetTask(1); task.Description != "Write tests" || task.Version != 1 {
+		t.Errorf("expected the task to be unchanged, got %+v", task)
+	}
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes of GET /tasks
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
+
+// taskSortFields lists the fields tasks can be sorted by
+var taskSortFields = map[string]bool{"id": true, "description": true, "completed": true, "created_at": true}
+
+// TaskQuery selects a page of tasks. Tasks are ordered by Sort and then by
+// ID, so the order is stable even when sort values repeat.
+type TaskQuery struct {
+	Completed  *bool
+	Sort       string
+	Descending bool
+	Limit      int
+	Cursor     *TaskCursor
+}
+
+// TaskCursor marks a position in a sorted list of tasks. With Before set
+// the page ends just before the position, otherwise it starts just after it.
+type TaskCursor struct {
+	Sort   string          `json:"s"`
+	Key    json.RawMessage `json:"k"`
+	ID     int             `json:"id"`
+	Before bool            `json:"b,omitempty"`
+}
+
+// TaskPage is one page of tasks. Next and Prev are the cursors of the
+// neighbouring pages, empty when there is none.
+type TaskPage struct {
+	Tasks []*Task
+	Total int
+	Next  string
+	Prev  string
+}
+
+// ParseTaskQuery reads the completed, sort, limit and cursor parameters
+func ParseTaskQuery(values url.Values) (TaskQuery, error) {
+	query := TaskQuery{Sort: "id", Limit: DefaultPageSize}
+
+	if value := values.Get("completed"); value != "" {
+		completed, err := strconv.ParseBool(value)
+		if err != nil {
+			return query, &fieldError{field: "completed", key: "field.boolean"}
+		}
+		query.Completed = &completed
+	}
+
+	if value := values.Get("sort"); value != "" {
+		query.Descending = strings.HasPrefix(value, "-")
+		query.Sort = strings.TrimPrefix(value, "-")
+		if !taskSortFields[query.Sort] {
+			return query, &fieldError{field: "sort", key: "field.sort"}
+		}
+	}
+
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > MaxPageSize {
+			return query, &fieldError{field: "limit", key: "field.range", args: []interface{}{1, MaxPageSize}}
+		}
+		query.Limit = limit
+	}
+
+	if value := values.Get("cursor"); value != "" {
+		cursor, err := decodeCursor(value)
+		if err != nil || cursor.Sort != sortParam(query.Sort, query.Descending) {
+			return query, &fieldError{field: "cursor", key: "field.invalid"}
+		}
+		if _, err := cursor.key(query.Sort); err != nil {
+			return query, &fieldError{field: "cursor", key: "field.invalid"}
+		}
+		query.Cursor = cursor
+	}
+	return query, nil
+}
+
+// Returns the value of the sort parameter for a field and direction
+func sortParam(field string, descending bool) string {
+	if descending {
+		return "-" + field
+	}
+	return field
+}
+
+// Returns the cursor of the position of a task in a query
+func (q TaskQuery) cursorAt(task *Task, before bool) string {
+	key, _ := json.Marshal(taskSortValue(task, q.Sort))
+	cursor := TaskCursor{Sort: sortParam(q.Sort, q.Descending), Key: key, ID: task.ID, Before: before}
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Fills in the cursors of the neighbouring pages. tasks holds the page in
+// order; more is true when tasks exist past the far end of the page.
+func (q TaskQuery) page(tasks []*Task, more bool, total int) TaskPage {
+	page := TaskPage{Tasks: tasks, Total: total}
+	if len(tasks) == 0 {
+		return page
+	}
+	backward := q.Cursor != nil && q.Cursor.Before
+	if more || backward {
+		page.Next = q.cursorAt(tasks[len(tasks)-1], false)
+	}
+	if (more && backward) || (q.Cursor != nil && !backward) {
+		page.Prev = q.cursorAt(tasks[0], true)
+	}
+	return page
+}
+
+// Decodes an opaque cursor
+func decodeCursor(value string) (*TaskCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var cursor TaskCursor
+	if err := json.Unmarshal(data, &cursor); err != nil {
+		return nil, err
+	}
+	return &cursor, nil
+}
+
+// Returns the value a task is sorted by
+func taskSortValue(task *Task, field string) interface{} {
+	switch field {
+	case "description":
+		return task.Description
+	case "completed":
+		return task.Completed
+	case "created_at":
+		return task.CreatedAt
+	default:
+		return task.ID
+	}
+}
+
+// Returns the sort value stored in a cursor with the type of the field
+func (c *TaskCursor) key(field string) (interface{}, error) {
+	var err error
+	switch field {
+	case "description":
+		var description string
+		err = json.Unmarshal(c.Key, &description)
+		return description, err
+	case "completed":
+		var completed bool
+		err = json.Unmarshal(c.Key, &completed)
+		return completed, err
+	case "created_at":
+		var createdAt time.Time
+		err = json.Unmarshal(c.Key, &createdAt)
+		return createdAt, err
+	default:
+		var id int
+		err = json.Unmarshal(c.Key, &id)
+		return id, err
+	}
+}
+
+// setPageHeaders sets the X-Total-Count header and a Link header with the
+// next and previous pages
+func setPageHeaders(w http.ResponseWriter, r *http.Request, page TaskPage) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(page.Total))
+	var links []string
+	for _, link := range []struct{ rel, cursor string }{{"next", page.Next}, {"prev", page.Prev}} {
+		if link.cursor == "" {
+			continue
+		}
+		values := r.URL.Query()
+		values.Set("cursor", link.cursor)
+		links = append(links, fmt.Sprintf(`<%s?%s>; rel="%s"`, r.URL.Path, values.Encode(), link.rel))
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+)
+
+var linkPattern = regexp.MustCompile(`<([^>]*)>; rel="(next|prev)"`)
+
+// Requests a page of tasks and returns the descriptions and the links
+func getTaskPage(t *testing.T, router http.Handler, url string) ([]string, map[string]string) {
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", url, nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("GET %s: expected status %d, got %d: %s", url, http.StatusOK, rr.Code, rr.Body.String())
+	}
+	var tasks []Task
+	if err := json.NewDecoder(rr.Body).Decode(&tasks); err != nil {
+		t.Fatal(err)
+	}
+	descriptions := make([]string, len(tasks))
+	for i, task := range tasks {
+		descriptions[i] = task.Description
+	}
+	links := make(map[string]string)
+	for _, match := range linkPattern.FindAllStringSubmatch(rr.Header().Get("Link"), -1) {
+		links[match[2]] = match[1]
+	}
+	if total := rr.Header().Get("X-Total-Count"); total != "4" {
+		t.Errorf("GET %s: expected X-Total-Count 4, got %q", url, total)
+	}
+	return descriptions, links
+}
+
+func TestTaskPagination(t *testing.T) {
+	tm := NewTaskManager()
+	for _, description := range []string{"b", "a", "c", "a", "d", "e"} {
+		tm.AddTask(description)
+	}
+	tm.UpdateTask(5, "d", true, Precondition{})
+	tm.UpdateTask(6, "e", true, Precondition{})
+	router := newRouter(tm)
+
+	pages := [][]string{{"c", "b"}, {"a", "a"}}
+	descriptions, links := getTaskPage(t, router, "/tasks?completed=false&sort=-description&limit=2")
+	if len(links) != 1 || links["next"] == "" {
+		t.Fatalf("expected only a next link on the first page, got %v", links)
+	}
+	assertPage(t, descriptions, pages[0])
+
+	descriptions, links = getTaskPage(t, router, links["next"])
+	if len(links) != 1 || links["prev"] == "" {
+		t.Fatalf("expected only a prev link on the last page, got %v", links)
+	}
+	assertPage(t, descriptions, pages[1])
+
+	descriptions, _ = getTaskPage(t, router, links["prev"])
+	assertPage(t, descriptions, pages[0])
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/tasks?sort=priority", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for an unknown sort field, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func assertPage(t *testing.T, got, expected []string) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("expected page %v, got %v", expected, got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Fatalf("expected page %v, got %v", expected, got)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRouterDispatchesByMethodAndPath(t *testing.T) {
+	tm := NewTaskManager()
+	tm.AddTask("Write tests")
+	router := newRouter(tm)
+
+	tests := []struct {
+		method string
+		path   string
+		status int
+		allow  string
+	}{
+		{"GET", "/tasks/1", http.StatusOK, ""},
+		{"GET", "/tasks/1/", http.StatusOK, ""},
+		{"GET", "/tasks/abc", http.StatusBadRequest, ""},
+		{"GET", "/tasks/1/extra", http.StatusNotFound, ""},
+		{"POST", "/tasks/1", http.StatusMethodNotAllowed, "DELETE, GET, HEAD, OPTIONS, PATCH, PUT"},
+		{"DELETE", "/tasks", http.StatusMethodNotAllowed, "GET, HEAD, OPTIONS, POST"},
+		{"OPTIONS", "/tasks", http.StatusNoContent, "GET, HEAD, OPTIONS, POST"},
+		{"HEAD", "/tasks", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, req)
+
+		if rr.Code != tt.status {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.path, tt.status, rr.Code)
+		}
+		if allow := rr.Header().Get("Allow"); allow != tt.allow {
+			t.Errorf("%s %s: expected Allow %q, got %q", tt.method, tt.path, tt.allow, allow)
+		}
+	}
+}
+
+func TestRouteGroupAppliesMiddleware(t *testing.T) {
+	router := NewRouter()
+	deny := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+		})
+	}
+	ok := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.PathValue("name")))
+	}
+	router.HandleFunc("GET /public/{name}", ok)
+	router.Group(deny).HandleFunc("GET /private/{name}", ok)
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/public/alice", nil))
+	if rr.Code != http.StatusOK || strings.TrimSpace(rr.Body.String()) != "alice" {
+		t.Errorf("expected public route to answer alice, got %d %q", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	router.ServeHTTP(rr, httptest.NewRequest("GET", "/private/alice", nil))
+	if rr.Code != http.StatusUnauthorized {
+		t.Errorf("expected private route to be denied, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServerConfigFromFlagsAndEnv(t *testing.T) {
+	t.Setenv("ADDR", ":9090")
+	t.Setenv("WRITE_TIMEOUT", "45s")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	config := RegisterServerFlags(fs)
+	if err := fs.Parse([]string{"-idle-timeout", "1m"}); err != nil {
+		t.Fatal(err)
+	}
+	if config.Addr != ":9090" || config.WriteTimeout != 45*time.Second || config.IdleTimeout != time.Minute || config.ReadTimeout != 10*time.Second {
+		t.Errorf("expected the environment and flags over the defaults, got %+v", config)
+	}
+}
+
+func TestServeDrainsRequestsInFlight(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(100 * time.Millisecond)
+		io.WriteString(w, "done")
+	})
+
+	config := &ServerConfig{ShutdownTimeout: 5 * time.Second}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- config.Serve(ctx, config.NewServer(handler), listener)
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	responses := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + listener.Addr().String())
+		if err != nil {
+			responses <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+	if r := <-responses; r.err != nil || r.body != "done" {
+		t.Errorf("expected the request in flight to finish, got %q, %v", r.body, r.err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("expected a clean shutdown, got %v", err)
+	}
+	if _, err := http.Get("http://" + listener.Addr().String()); err == nil {
+		t.Error("expected new connections to be refused after shutdown")
+	}
+}
+
+func TestServeWaitsForHandlersPastTheTimeout(t *testing.T) {
+	started, finished := make(chan struct{}), make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		// Ignores the request context, like a slow database call
+		time.Sleep(300 * time.Millisecond)
+		close(finished)
+	})
+
+	config := &ServerConfig{ShutdownTimeout: 50 * time.Millisecond}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- config.Serve(ctx, config.NewServer(handler), listener)
+	}()
+	go http.Get("http://" + listener.Addr().String())
+
+	<-started
+	cancel()
+	err = <-served
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the shutdown to time out, got %v", err)
+	}
+	select {
+	case <-finished:
+	default:
+		t.Error("expected Serve to return only after the handler did")
+	}
+}
+
+func TestHandlerGroupRefusesRequestsAfterWait(t *testing.T) {
+	handlers := &handlerGroup{}
+	handler := handlers.track(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("expected no handler to start after wait")
+	}))
+	handlers.wait()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/tasks", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Errorf("expected a 503 problem, got %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Types of the messages clients send over the task WebSocket
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsCreate      = "create"
+	wsUpdate      = "update"
+	wsPatch       = "patch"
+	wsDelete      = "delete"
+)
+
+const (
+	// Time allowed to write a message to the client
+	wsWriteWait = 10 * time.Second
+	// Time allowed between messages or pongs from the client
+	wsPongWait = 60 * time.Second
+	// Interval of the pings, shorter than wsPongWait so a pong can arrive
+	wsPingPeriod = wsPongWait * 9 / 10
+	// Largest message accepted from the client
+	wsMaxMessageSize = 64 << 10
+	// Number of messages queued for a client before it counts as too slow
+	wsSendBuffer = 64
+)
+
+var errUnknownMessage = errors.New("unknown message type")
+
+// invalidPatchError wraps the error of a patch message that could not be
+// applied to the task
+type invalidPatchError struct {
+	err error
+}
+
+func (e *invalidPatchError) Error() string { return e.err.Error() }
+
+func (e *invalidPatchError) Unwrap() error { return e.err }
+
+var upgrader = websocket.Upgrader{ReadBufferSize: 1024, WriteBufferSize: 1024}
+
+// wsMessage is a message of the task WebSocket in either direction. Clients
+// send subscribe, unsubscribe, create, update, patch and delete messages with
+// an ID of their choice. The server answers each with an ack or an error
+// message carrying the same ID, sends a snapshot on subscribe, and pushes
+// created, updated and deleted events to subscribers.
+type wsMessage struct {
+	ID          string          `json:"id,omitempty"`
+	Type        string          `json:"type"`
+	TaskID      int             `json:"task_id,omitempty"`
+	Version     int             `json:"version,omitempty"`
+	Task        *Task           `json:"task,omitempty"`
+	Patch       json.RawMessage `json:"patch,omitempty"`
+	LastEventID string          `json:"last_event_id,omitempty"`
+	EventID     string          `json:"event_id,omitempty"`
+	Tasks       []*Task         `json:"tasks,omitempty"`
+	Total       int             `json:"total,omitempty"`
+	Error       *Problem        `json:"error,omitempty"`
+}
+
+// A WebSocket connection of a client
+type wsClient struct {
+	conn    *websocket.Conn
+	tm      *TaskManager
+	request *http.Request
+	send    chan wsMessage
+	events  chan TaskEvent
+	stopped chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	closing websocket.CloseError
+}
+
+// taskSocketHandler upgrades the request to a WebSocket on which clients
+// subscribe to the tasks and change them
+func taskSocketHandler(tm *TaskManager) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade has already replied with an error
+			return
+		}
+		client := &wsClient{
+			conn:    conn,
+			tm:      tm,
+			request: r,
+			send:    make(chan wsMessage, wsSendBuffer),
+			done:    make(chan struct{}),
+		}
+		go client.writeLoop()
+		go client.closeOnShutdown()
+		client.readLoop()
+	}
+}
+
+// Closes the connection when the broker closes at shutdown. The server does
+// not wait for hijacked connections, so they have to end on their own.
+func (c *wsClient) closeOnShutdown() {
+	select {
+	case <-c.tm.Events().Done():
+		c.close(websocket.CloseTryAgainLater, "server shutting down")
+	case <-c.done:
+	}
+}
+
+// Reads and handles the messages of the client until the connection fails
+// or closes
+func (c *wsClient) readLoop() {
+	defer c.unsubscribe()
+	defer c.close(websocket.CloseNormalClosure, "")
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var message wsMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			c.replyError(message, http.StatusBadRequest, "error.invalid_body", nil, decodeFieldError(err))
+			continue
+		}
+		c.handle(message)
+	}
+}
+
+// Handles one message of the client
+func (c *wsClient) handle(message wsMessage) {
+	switch message.Type {
+	case wsSubscribe:
+		c.subscribe(message)
+	case wsUnsubscribe:
+		c.unsubscribe()
+		c.reply(wsMessage{ID: message.ID, Type: "ack"})
+	case wsCreate, wsUpdate, wsPatch, wsDelete:
+		task, err := c.tm.applyMutation(message)
+		if err != nil {
+			c.mutationError(message, err)
+			return
+		}
+		c.reply(wsMessage{ID: message.ID, Type: "ack", Task: task})
+	default:
+		c.replyError(message, http.StatusBadRequest, "error.invalid_message", nil, &fieldError{field: "type", key: "field.invalid"})
+	}
+}
+
+// Subscribes the client to the task events. A client that resubscribes with
+// the ID of the last event it got receives the events it missed; otherwise
+// it receives a snapshot of all the tasks.
+func (c *wsClient) subscribe(message wsMessage) {
+	c.unsubscribe()
+	missed, events, resetID := c.tm.Events().Subscribe(message.LastEventID)
+	c.events, c.stopped = events, make(chan struct{})
+
+	if message.LastEventID == "" || resetID != "" {
+		tasks, err := c.allTasks()
+		if err != nil {
+			c.mutationError(message, err)
+			return
+		}
+		c.reply(wsMessage{ID: message.ID, Type: "snapshot", EventID: resetID, Tasks: tasks, Total: len(tasks)})
+	} else {
+		c.reply(wsMessage{ID: message.ID, Type: "ack"})
+	}
+	for _, event := range missed {
+		c.reply(eventMessage(event))
+	}
+	go c.forward(events, c.stopped)
+}
+
+// Reads all the tasks for a snapshot a page at a time. Writes between pages
+// reach the client as events, since it subscribed first.
+func (c *wsClient) allTasks() ([]*Task, error) {
+	query := TaskQuery{Sort: "id", Limit: MaxPageSize}
+	var tasks []*Task
+	for {
+		page, err := c.tm.QueryTasks(query)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, page.Tasks...)
+		if page.Next == "" {
+			return tasks, nil
+		}
+		if query.Cursor, err = decodeCursor(page.Next); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Passes the events of a subscription to the client until stopped is closed.
+// A client whose queue is full is disconnected rather than slowing down the
+// other clients; it resubscribes with the ID of the last event it got.
+func (c *wsClient) forward(events chan TaskEvent, stopped chan struct{}) {
+	for event := range events {
+		select {
+		case c.send <- eventMessage(event):
+		case <-c.done:
+			return
+		default:
+			c.close(websocket.CloseTryAgainLater, "too slow; resubscribe with last_event_id")
+			return
+		}
+	}
+	// Unless the client unsubscribed, the broker dropped the subscription or
+	// is closing
+	select {
+	case <-stopped:
+	case <-c.done:
+	default:
+		c.close(websocket.CloseTryAgainLater, "subscription ended; resubscribe with last_event_id")
+	}
+}
+
+// Ends the subscription of the client, if any
+func (c *wsClient) unsubscribe() {
+	if c.events != nil {
+		close(c.stopped)
+		c.tm.Events().Unsubscribe(c.events)
+		c.events = nil
+	}
+}
+
+// Queues a message for the client. It waits while the queue is full, so a
+// client that does not read its replies stops being read from.
+func (c *wsClient) reply(message wsMessage) {
+	select {
+	case c.send <- message:
+	case <-c.done:
+	}
+}
+
+// Replies with an error message describing a failed request
+func (c *wsClient) replyError(message wsMessage, status int, key string, args []interface{}, errs ...*fieldError) {
+	problem := newProblem(c.request, translatorFor(c.request), status, key, args, errs)
+	c.reply(wsMessage{ID: message.ID, Type: "error", Error: &problem})
+}
+
+// Replies with the error of a mutation, with the status the REST handlers
+// would use
+func (c *wsClient) mutationError(message wsMessage, err error) {
+	var invalid *fieldError
+	var operationErr *patchOperationError
+	switch {
+	case errors.Is(err, errUnknownMessage):
+		c.replyError(message, http.StatusBadRequest, "error.invalid_message", nil, &fieldError{field: "type", key: "field.invalid"})
+	case errors.Is(err, ErrTaskNotFound):
+		c.replyError(message, http.StatusNotFound, "error.task_not_found", nil)
+	case errors.Is(err, ErrVersionMismatch):
+		c.replyError(message, http.StatusPreconditionFailed, "error.version_mismatch", nil)
+	case errors.Is(err, ErrPreconditionRequired):
+		c.replyError(message, http.StatusPreconditionRequired, "error.precondition_required", nil)
+	case errors.Is(err, errPatchTestFailed):
+		c.replyError(message, http.StatusConflict, "error.patch_test_failed", nil)
+	case errors.Is(err, errMalformedPatch):
+		c.replyError(message, http.StatusBadRequest, "error.invalid_body", nil)
+	case errors.As(err, &invalid):
+		c.replyError(message, http.StatusUnprocessableEntity, "error.invalid_task", nil, invalid)
+	case errors.As(err, &operationErr):
+		c.replyError(message, http.StatusBadRequest, "error.invalid_patch", operationErr.problemArgs(translatorFor(c.request)))
+	default:
+		log.Printf("request %s: websocket %s: %v", requestID(c.request), message.Type, err)
+		c.replyError(message, http.StatusInternalServerError, "error.internal", nil)
+	}
+}
+
+// Writes the queued messages and the pings until the connection closes
+func (c *wsClient) writeLoop() {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+	defer c.conn.Close()
+
+	for {
+		select {
+		case message := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteJSON(message); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ping.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.done:
+			closing := websocket.FormatCloseMessage(c.closing.Code, c.closing.Text)
+			c.conn.WriteControl(websocket.CloseMessage, closing, time.Now().Add(wsWriteWait))
+			return
+		}
+	}
+}
+
+// Starts closing the connection with a close code; later calls do nothing
+func (c *wsClient) close(code int, text string) {
+	c.once.Do(func() {
+		c.closing = websocket.CloseError{Code: code, Text: text}
+		close(c.done)
+	})
+}
+
+// Returns the message of a task event
+func eventMessage(event TaskEvent) wsMessage {
+	return wsMessage{Type: event.Type, EventID: event.ID, TaskID: event.TaskID, Task: event.Task}
+}
+
+// Returns the task of a create or update message after validating it
+func messageTask(message wsMessage) (*Task, error) {
+	if message.Task == nil {
+		return nil, &fieldError{field: "task", key: "field.required"}
+	}
+	if err := validateTask(message.Task); err != nil {
+		return nil, err
+	}
+	return message.Task, nil
+}
+
+// Returns the precondition of a message: its version, if it has one
+func messagePrecondition(message wsMessage) Precondition {
+	if message.Version == 0 {
+		return Precondition{}
+	}
+	return IfVersion(message.Version)
+}
+
+// Returns the patch format of a patch message: a JSON Patch when it is an
+// array, a merge patch otherwise
+func messagePatchType(message wsMessage) string {
+	if bytes.HasPrefix(bytes.TrimSpace(message.Patch), []byte("[")) {
+		return jsonPatchType
+	}
+	return mergePatchType
+}
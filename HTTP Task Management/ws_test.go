@@ -0,0 +1,238 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Opens the task WebSocket of a test server
+func dialTasks(t *testing.T, server *httptest.Server) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(server.URL, "http") + "/tasks/ws"
+	conn, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected the connection to switch protocols, got %d", resp.StatusCode)
+	}
+	return conn
+}
+
+// Sends a message and returns the next message received
+func roundTrip(t *testing.T, conn *websocket.Conn, message wsMessage) wsMessage {
+	t.Helper()
+	if err := conn.WriteJSON(message); err != nil {
+		t.Fatal(err)
+	}
+	return readMessage(t, conn)
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) wsMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var message wsMessage
+	if err := conn.ReadJSON(&message); err != nil {
+		t.Fatalf("reading message: %v", err)
+	}
+	return message
+}
+
+func TestTaskSocketSubscribeAndMutate(t *testing.T) {
+	tm := NewTaskManager()
+	tm.AddTask("Write tests")
+	server := httptest.NewServer(newRouter(tm))
+	defer server.Close()
+
+	watcher, writer := dialTasks(t, server), dialTasks(t, server)
+	defer watcher.Close()
+	defer writer.Close()
+
+	snapshot := roundTrip(t, watcher, wsMessage{ID: "1", Type: wsSubscribe})
+	if snapshot.Type != "snapshot" || snapshot.ID != "1" || snapshot.Total != 1 || snapshot.Tasks[0].Description != "Write tests" {
+		t.Fatalf("expected a snapshot of the tasks, got %+v", snapshot)
+	}
+
+	ack := roundTrip(t, writer, wsMessage{ID: "a", Type: wsCreate, Task: &Task{Description: "Ship"}})
+	if ack.Type != "ack" || ack.ID != "a" || ack.Task == nil || ack.Task.ID != 2 {
+		t.Fatalf("expected an ack with the new task, got %+v", ack)
+	}
+	if created := readMessage(t, watcher); created.Type != TaskCreated || created.TaskID != 2 || created.EventID == "" {
+		t.Errorf("expected the subscriber to get the created event, got %+v", created)
+	}
+
+	ack = roundTrip(t, writer, wsMessage{ID: "b", Type: wsPatch, TaskID: 2, Version: 1, Patch: []byte(`{"completed":true}`)})
+	if ack.Type != "ack" || !ack.Task.Completed || ack.Task.Version != 2 {
+		t.Fatalf("expected the patched task, got %+v", ack)
+	}
+	updated := readMessage(t, watcher)
+	if updated.Type != TaskUpdated || !updated.Task.Completed {
+		t.Errorf("expected the subscriber to get the updated event, got %+v", updated)
+	}
+
+	stale := roundTrip(t, writer, wsMessage{ID: "c", Type: wsUpdate, TaskID: 2, Version: 1, Task: &Task{Description: "Ship it"}})
+	if stale.Type != "error" || stale.ID != "c" || stale.Error.Status != http.StatusPreconditionFailed {
+		t.Errorf("expected a 412 error for a stale version, got %+v", stale)
+	}
+	unknown := roundTrip(t, writer, wsMessage{ID: "d", Type: "archive"})
+	if unknown.Type != "error" || unknown.Error.Status != http.StatusBadRequest {
+		t.Errorf("expected a 400 error for an unknown type, got %+v", unknown)
+	}
+
+	// Resubscribing with the last event ID replays what was missed
+	if ack := roundTrip(t, watcher, wsMessage{Type: wsUnsubscribe}); ack.Type != "ack" {
+		t.Fatalf("expected an ack to unsubscribe, got %+v", ack)
+	}
+	roundTrip(t, writer, wsMessage{Type: wsDelete, TaskID: 1})
+	if ack := roundTrip(t, watcher, wsMessage{Type: wsSubscribe, LastEventID: updated.EventID}); ack.Type != "ack" {
+		t.Fatalf("expected an ack to resubscribe, got %+v", ack)
+	}
+	if deleted := readMessage(t, watcher); deleted.Type != TaskDeleted || deleted.TaskID != 1 || deleted.Task != nil {
+		t.Errorf("expected the missed delete, got %+v", deleted)
+	}
+
+	// Closing the broker asks subscribers to reconnect
+	tm.Events().Close()
+	watcher.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := watcher.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+		t.Errorf("expected a try-again-later close, got %v", err)
+	}
+	// Connections without a subscription are closed too
+	writer.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, _, err := writer.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseTryAgainLater) {
+		t.Errorf("expected a try-again-later close without a subscription, got %v", err)
+	}
+}
+
+func TestTaskSocketSnapshotHasEveryTask(t *testing.T) {
+	tm := NewTaskManager()
+	for i := 0; i < MaxPageSize+5; i++ {
+		tm.AddTask("Task " + strconv.Itoa(i))
+	}
+	server := httptest.NewServer(newRouter(tm))
+	defer server.Close()
+	conn := dialTasks(t, server)
+	defer conn.Close()
+
+	snapshot := roundTrip(t, conn, wsMessage{ID: "1", Type: wsSubscribe})
+	if snapshot.Type != "snapshot" || snapshot.Total != MaxPageSize+5 || len(snapshot.Tasks) != MaxPageSize+5 {
+		t.Fatalf("expected all %d tasks, got %d of %d", MaxPageSize+5, len(snapshot.Tasks), snapshot.Total)
+	}
+	for i, task := range snapshot.Tasks {
+		if task.ID != i+1 {
+			t.Fatalf("expected task %d at %d, got %d", i+1, i, task.ID)
+		}
+	}
+}
+
+// Run with -race: handlers must only encode copies of the stored tasks
+func TestConcurrentWritesOverHTTPAndSocket(t *testing.T) {
+	tm := NewTaskManager()
+	tm.AddTask("Write tests")
+	server := httptest.NewServer(newRouter(tm))
+	defer server.Close()
+
+	watcher, writer := dialTasks(t, server), dialTasks(t, server)
+	defer watcher.Close()
+	defer writer.Close()
+	if snapshot := roundTrip(t, watcher, wsMessage{ID: "s", Type: wsSubscribe}); snapshot.Type != "snapshot" {
+		t.Fatalf("expected a snapshot, got %+v", snapshot)
+	}
+
+	const rounds = 100
+	// The watcher reads while the writes run, so it never falls behind
+	events := make(chan int)
+	go func() {
+		updates := 0
+		watcher.SetReadDeadline(time.Now().Add(30 * time.Second))
+		for updates < 3*rounds {
+			var event wsMessage
+			if err := watcher.ReadJSON(&event); err != nil {
+				t.Errorf("reading event: %v", err)
+				break
+			}
+			if event.Type == TaskUpdated {
+				updates++
+			}
+		}
+		events <- updates
+	}()
+
+	var wg sync.WaitGroup
+	send := func(method, body string) {
+		req, err := http.NewRequest(method, server.URL+"/tasks/1", strings.NewReader(body))
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if method == http.MethodPatch {
+			req.Header.Set("Content-Type", mergePatchType)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected %s to succeed, got %d", method, resp.StatusCode)
+		}
+	}
+	wg.Add(4)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			send(http.MethodPut, `{"description": "Put `+strconv.Itoa(i)+`"}`)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			send(http.MethodPatch, `{"description": "Patch `+strconv.Itoa(i)+`"}`)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			send(http.MethodGet, "")
+			resp, err := http.Get(server.URL + "/tasks")
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		writer.SetReadDeadline(time.Now().Add(30 * time.Second))
+		for i := 0; i < rounds; i++ {
+			patch := []byte(`{"description": "Socket ` + strconv.Itoa(i) + `"}`)
+			var ack wsMessage
+			if err := writer.WriteJSON(wsMessage{ID: strconv.Itoa(i), Type: wsPatch, TaskID: 1, Patch: patch}); err != nil {
+				t.Error(err)
+				return
+			}
+			if err := writer.ReadJSON(&ack); err != nil || ack.Type != "ack" {
+				t.Errorf("expected an ack, got %+v, %v", ack, err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	// Every write changed the description, so each made a new version
+	if task, _ := tm.GetTask(1); task.Version != 1+3*rounds {
+		t.Errorf("expected version %d, got %d", 1+3*rounds, task.Version)
+	}
+	if updates := <-events; updates != 3*rounds {
+		t.Errorf("expected %d updated events, got %d", 3*rounds, updates)
+	}
+}
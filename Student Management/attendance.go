@@ -0,0 +1,176 @@
+package main
+
+import (
+	"errors"
+	"time"
+)
+
+// AttendanceStatus describes how a student attended a session
+type AttendanceStatus int
+
+// Attendance statuses
+const (
+	Present AttendanceStatus = iota
+	Absent
+	Late
+	Excused
+)
+
+// String returns the name of the attendance status
+func (s AttendanceStatus) String() string {
+	switch s {
+	case Present:
+		return "present"
+	case Absent:
+		return "absent"
+	case Late:
+		return "late"
+	case Excused:
+		return "excused"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrAmbiguousStudent is returned when a name matches more than one student;
+// the student number tells them apart
+var ErrAmbiguousStudent = errors.New("more than one student has this name; use the student number")
+
+// Session struct
+type Session struct {
+	ID     int
+	Date   time.Time
+	Roster []*Student
+}
+
+// AttendanceBook keeps sessions and the attendance recorded for them
+type AttendanceBook struct {
+	sessions  map[int]*Session
+	records   map[int]map[string]AttendanceStatus
+	threshold float64
+}
+
+// NewAttendanceBook creates a new AttendanceBook that flags students below threshold percent
+func NewAttendanceBook(threshold float64) *AttendanceBook {
+	return &AttendanceBook{
+		sessions:  make(map[int]*Session),
+		records:   make(map[int]map[string]AttendanceStatus),
+		threshold: threshold,
+	}
+}
+
+// NewSession creates a session whose roster holds the active students only
+func (ab *AttendanceBook) NewSession(date time.Time, students []Person) *Session {
+	session := &Session{ID: len(ab.sessions) + 1, Date: date}
+	for _, student := range students {
+		if s := student.(*Student); s.IsActive {
+			session.Roster = append(session.Roster, s)
+		}
+	}
+	ab.sessions[session.ID] = session
+	ab.records[session.ID] = make(map[string]AttendanceStatus)
+	return session
+}
+
+// Record records the attendance of a student, by name or student number, for
+// a session
+func (ab *AttendanceBook) Record(sessionID int, key string, status AttendanceStatus) error {
+	session, exists := ab.sessions[sessionID]
+	if !exists {
+		return errors.New("session not found")
+	}
+	student, err := rosterStudent(session.Roster, key)
+	if err != nil {
+		return err
+	}
+	if student == nil {
+		return errors.New("student is not on the session roster")
+	}
+	ab.records[sessionID][studentKey(student)] = status
+	return nil
+}
+
+// Lookup finds a student on the roster of any session by name or student
+// number. It returns nil when no student matches.
+func (ab *AttendanceBook) Lookup(key string) (*Student, error) {
+	var roster []*Student
+	seen := make(map[string]bool)
+	for id := 1; id <= len(ab.sessions); id++ {
+		for _, student := range ab.sessions[id].Roster {
+			if !seen[studentKey(student)] {
+				seen[studentKey(student)] = true
+				roster = append(roster, student)
+			}
+		}
+	}
+	return rosterStudent(roster, key)
+}
+
+// AttendancePercentage returns the percentage of sessions between from and to
+// that the student attended. Late counts as attended and excused sessions are
+// left out. The boolean is false when there is nothing to count.
+func (ab *AttendanceBook) AttendancePercentage(student *Student, from, to time.Time) (float64, bool) {
+	key := studentKey(student)
+	attended, counted := 0, 0
+	for id, session := range ab.sessions {
+		if session.Date.Before(from) || session.Date.After(to) {
+			continue
+		}
+		status, recorded := ab.records[id][key]
+		if !recorded || status == Excused {
+			continue
+		}
+		counted++
+		if status == Present || status == Late {
+			attended++
+		}
+	}
+	if counted == 0 {
+		return 0, false
+	}
+	return float64(attended) * 100 / float64(counted), true
+}
+
+// FlaggedStudents returns the students whose attendance between from and to
+// is below the threshold of the book. Deactivated students keep their history
+// and are still reported.
+func (ab *AttendanceBook) FlaggedStudents(from, to time.Time) []Person {
+	var flagged []Person
+	seen := make(map[string]bool)
+	for id := 1; id <= len(ab.sessions); id++ {
+		for _, student := range ab.sessions[id].Roster {
+			if seen[studentKey(student)] {
+				continue
+			}
+			seen[studentKey(student)] = true
+			percentage, ok := ab.AttendancePercentage(student, from, to)
+			if ok && percentage < ab.threshold {
+				flagged = append(flagged, student)
+			}
+		}
+	}
+	return flagged
+}
+
+// Finds a student in a roster by student number, or else by name. A name
+// that more than one student in the roster has is ambiguous.
+func rosterStudent(roster []*Student, key string) (*Student, error) {
+	var named []*Student
+	for _, student := range roster {
+		// A match that is not by name is by student number
+		if matchesStudent(student, key) && student.Name != key {
+			return student, nil
+		}
+		if student.Name == key {
+			named = append(named, student)
+		}
+	}
+	switch len(named) {
+	case 0:
+		return nil, nil
+	case 1:
+		return named[0], nil
+	default:
+		return nil, ErrAmbiguousStudent
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAttendancePercentageAndFlagging(t *testing.T) {
+	alice := &Student{Number: "S1", Name: "Alice", IsActive: true}
+	bob := &Student{Number: "S2", Name: "Bob", IsActive: true}
+	carol := &Student{Number: "S3", Name: "Carol"}
+	book := NewAttendanceBook(75)
+	start := time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC)
+
+	statuses := map[*Student][]AttendanceStatus{
+		alice: {Present, Late, Excused, Present},
+		bob:   {Present, Absent, Absent, Late},
+	}
+	for day := 0; day < 4; day++ {
+		session := book.NewSession(start.AddDate(0, 0, day), []Person{alice, bob, carol})
+		if len(session.Roster) != 2 {
+			t.Fatalf("expected only the active students on the roster, got %d", len(session.Roster))
+		}
+		for student, list := range statuses {
+			if err := book.Record(session.ID, student.Number, list[day]); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := book.Record(1, "Carol", Present); err == nil {
+		t.Error("expected recording a student who is not on the roster to fail")
+	}
+
+	end := start.AddDate(0, 0, 3)
+	if percentage, ok := book.AttendancePercentage(alice, start, end); !ok || percentage != 100 {
+		t.Errorf("expected late to count as attended and excused to be left out, got %v, %v", percentage, ok)
+	}
+	if percentage, ok := book.AttendancePercentage(bob, start, end); !ok || percentage != 50 {
+		t.Errorf("expected 50%% for Bob, got %v, %v", percentage, ok)
+	}
+	if percentage, ok := book.AttendancePercentage(bob, start.AddDate(0, 0, 1), start.AddDate(0, 0, 2)); !ok || percentage != 0 {
+		t.Errorf("expected the range to limit the sessions, got %v, %v", percentage, ok)
+	}
+	if _, ok := book.AttendancePercentage(carol, start, end); ok {
+		t.Error("expected nothing to count for a student without records")
+	}
+
+	flagged := book.FlaggedStudents(start, end)
+	if len(flagged) != 1 || flagged[0] != bob {
+		t.Errorf("expected only Bob below the threshold, got %v", flagged)
+	}
+}
+
+func TestAttendanceResolvesSharedNamesByNumber(t *testing.T) {
+	first := &Student{Number: "S1", Name: "Alex", IsActive: true}
+	second := &Student{Number: "S2", Name: "Alex", IsActive: true}
+	book := NewAttendanceBook(75)
+	session := book.NewSession(time.Now(), []Person{first, second})
+
+	if err := book.Record(session.ID, "Alex", Present); !errors.Is(err, ErrAmbiguousStudent) {
+		t.Errorf("expected a shared name to be ambiguous, got %v", err)
+	}
+	if _, err := book.Lookup("Alex"); !errors.Is(err, ErrAmbiguousStudent) {
+		t.Errorf("expected the lookup of a shared name to be ambiguous, got %v", err)
+	}
+	if err := book.Record(session.ID, "S2", Absent); err != nil {
+		t.Fatal(err)
+	}
+	if student, err := book.Lookup("S2"); err != nil || student != second {
+		t.Errorf("expected the number to find the second Alex, got %v, %v", student, err)
+	}
+	if _, ok := book.AttendancePercentage(first, session.Date, session.Date); ok {
+		t.Error("expected the record to belong to the second Alex only")
+	}
+}
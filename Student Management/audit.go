@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// StatusChange records a change of the active status of a student
+type StatusChange struct {
+	At     time.Time
+	Actor  string
+	Reason string
+	Active bool
+}
+
+// Actor and reason of the first entry of a timeline, which records the
+// status a student was registered with
+const (
+	registrationActor  = "registration"
+	registrationReason = "registered"
+)
+
+// Actor of the status changes made by merging duplicate records
+const mergeActor = "merge"
+
+// StatusTimeline returns every status change of the student, oldest first
+func (s *Student) StatusTimeline() []StatusChange {
+	timeline := make([]StatusChange, len(s.history))
+	copy(timeline, s.history)
+	return timeline
+}
+
+// LastStatusChange returns the most recent change to the given status
+func (s *Student) LastStatusChange(active bool) (StatusChange, bool) {
+	for i := len(s.history) - 1; i >= 0; i-- {
+		if s.history[i].Active == active {
+			return s.history[i], true
+		}
+	}
+	return StatusChange{}, false
+}
+
+// Sets the active status and records the change when the status flips
+func (s *Student) setActive(active bool, actor, reason string) {
+	if s.IsActive == active {
+		return
+	}
+	s.IsActive = active
+	s.history = append(s.history, StatusChange{
+		At:     time.Now(),
+		Actor:  actor,
+		Reason: reason,
+		Active: active,
+	})
+}
+
+// Records the status of a newly registered student, unless its history
+// already has entries
+func (s *Student) recordRegistration() {
+	if len(s.history) > 0 {
+		return
+	}
+	s.history = append(s.history, StatusChange{
+		At:     time.Now(),
+		Actor:  registrationActor,
+		Reason: registrationReason,
+		Active: s.IsActive,
+	})
+}
+
+// Displays the status timeline of a student
+func displayStatusTimeline(student Person) {
+	if student == nil {
+		return
+	}
+	fmt.Println("\n" + translator.T("timeline.title", student.GetName()))
+	for _, change := range student.(*Student).StatusTimeline() {
+		fmt.Println(translator.T("timeline.entry",
+			translator.Date(change.At), translator.Bool(change.Active), change.Actor, change.Reason))
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import "testing"
+
+func TestStatusTimelineStartsAtRegistration(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register(Student{Name: "Alice", BirthYear: 2003, IsActive: true})
+	registry.Register(Student{Name: "Bob", BirthYear: 2003})
+
+	registry.Deactivate("Alice", "registrar", "withdrew")
+	registry.Deactivate("Alice", "registrar", "already inactive")
+	registry.Activate("Alice", "principal", "returned")
+
+	alice, _ := registry.Find("Alice")
+	timeline := alice.StatusTimeline()
+	if len(timeline) != 3 {
+		t.Fatalf("expected the registration and two changes, got %v", timeline)
+	}
+	if first := timeline[0]; !first.Active || first.Actor != registrationActor || first.Reason != registrationReason {
+		t.Errorf("expected the timeline to start with the active registration, got %+v", first)
+	}
+	if timeline[1].Active || timeline[1].Reason != "withdrew" || !timeline[2].Active || timeline[2].Actor != "principal" {
+		t.Errorf("expected the deactivation and the activation in order, got %v", timeline[1:])
+	}
+	if change, ok := alice.LastStatusChange(false); !ok || change.Reason != "withdrew" {
+		t.Errorf("expected the last deactivation, got %+v, %v", change, ok)
+	}
+
+	bob, _ := registry.Find("Bob")
+	if timeline := bob.StatusTimeline(); len(timeline) != 1 || timeline[0].Active {
+		t.Errorf("expected an inactive registration for Bob, got %v", timeline)
+	}
+}
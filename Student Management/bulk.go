@@ -0,0 +1,153 @@
+package main
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrNoFilter is returned for a bulk operation without a filter. A bulk
+// change to every student has to say so with a filter that matches them all.
+var ErrNoFilter = errors.New("bulk operation needs a filter")
+
+// BulkAction is a lifecycle change applied to many students at once
+type BulkAction int
+
+// Bulk actions
+const (
+	BulkDeactivate BulkAction = iota
+	BulkActivate
+	BulkArchive
+)
+
+// String returns the name of the bulk action
+func (a BulkAction) String() string {
+	switch a {
+	case BulkDeactivate:
+		return "deactivate"
+	case BulkActivate:
+		return "activate"
+	case BulkArchive:
+		return "archive"
+	default:
+		return "unknown"
+	}
+}
+
+// BulkOperation describes a bulk change to the students matched by Filter,
+// which is required. With DryRun set the registry only reports what would
+// change.
+type BulkOperation struct {
+	Action BulkAction
+	Filter StudentFilter
+	Actor  string
+	Reason string
+	DryRun bool
+}
+
+// BulkSummary reports the outcome of a bulk operation
+type BulkSummary struct {
+	Action  BulkAction
+	DryRun  bool
+	Changed []string
+	Skipped []string
+}
+
+// String returns a readable summary of the bulk operation in English
+func (s BulkSummary) String() string {
+	return s.Describe(NewTranslator(English))
+}
+
+// Describe returns a readable summary of the bulk operation in the locale of
+// the translator
+func (s BulkSummary) Describe(tr *Translator) string {
+	key := "bulk.changed"
+	if s.DryRun {
+		key = "bulk.would_change"
+	}
+	action := tr.T("bulk.action." + s.Action.String())
+	summary := tr.N(key, len(s.Changed), action, len(s.Changed))
+	if len(s.Changed) > 0 {
+		summary += " [" + strings.Join(s.Changed, ", ") + "]"
+	}
+	if len(s.Skipped) > 0 {
+		summary += tr.T("bulk.skipped", len(s.Skipped)) + " [" + strings.Join(s.Skipped, ", ") + "]"
+	}
+	return summary
+}
+
+// Preview reports what a bulk operation would change without applying it
+func (r *StudentRegistry) Preview(op BulkOperation) (BulkSummary, error) {
+	op.DryRun = true
+	return r.Bulk(op)
+}
+
+// Bulk applies a lifecycle change to every student matched by the filter of
+// the operation. Students already in the requested state are skipped, as are
+// minors without guardian consent when activating and active students when
+// archiving. An operation without a filter fails with ErrNoFilter.
+func (r *StudentRegistry) Bulk(op BulkOperation) (BulkSummary, error) {
+	if op.Filter == nil {
+		return BulkSummary{}, ErrNoFilter
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	summary := BulkSummary{Action: op.Action, DryRun: op.DryRun}
+	var kept []*Student
+	for _, student := range r.students {
+		if !op.Filter(student) {
+			kept = append(kept, student)
+			continue
+		}
+
+		if !bulkApplies(op.Action, student) {
+			summary.Skipped = append(summary.Skipped, student.Name)
+			kept = append(kept, student)
+			continue
+		}
+		summary.Changed = append(summary.Changed, student.Name)
+		if op.DryRun {
+			kept = append(kept, student)
+			continue
+		}
+
+		switch op.Action {
+		case BulkDeactivate:
+			student.Deactivate(op.Actor, op.Reason)
+			kept = append(kept, student)
+		case BulkActivate:
+			student.Activate(op.Actor, op.Reason)
+			kept = append(kept, student)
+		case BulkArchive:
+			r.archived = append(r.archived, student)
+		}
+	}
+	r.students = kept
+	return summary, nil
+}
+
+// Archived returns a copy of every archived student
+func (r *StudentRegistry) Archived() []Person {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	students := make([]Person, len(r.archived))
+	for i, student := range r.archived {
+		students[i] = copyStudent(student)
+	}
+	return students
+}
+
+// Checks if a bulk action would change the student
+func bulkApplies(action BulkAction, student *Student) bool {
+	switch action {
+	case BulkDeactivate:
+		return student.IsActive
+	case BulkActivate:
+		return !student.IsActive && student.canActivate() == nil
+	case BulkArchive:
+		return !student.IsActive
+	default:
+		return false
+	}
+}
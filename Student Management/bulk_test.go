@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBulkPreviewChangesNothing(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register(Student{Name: "Alice", BirthYear: 2003, IsActive: true})
+	registry.Register(Student{Name: "Bob", BirthYear: 2005, IsActive: true})
+
+	op := BulkOperation{Action: BulkDeactivate, Filter: ActiveStudents, Actor: "registrar", Reason: "end of term"}
+	for _, run := range []func(BulkOperation) (BulkSummary, error){registry.Preview, func(op BulkOperation) (BulkSummary, error) {
+		op.DryRun = true
+		return registry.Bulk(op)
+	}} {
+		summary, err := run(op)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !summary.DryRun || !reflect.DeepEqual(summary.Changed, []string{"Alice", "Bob"}) {
+			t.Errorf("expected a dry run that would change Alice and Bob, got %+v", summary)
+		}
+		if summary.String() != "deactivate: would change 2 students [Alice, Bob]" {
+			t.Errorf("unexpected description %q", summary.String())
+		}
+	}
+	for _, student := range registry.Snapshot() {
+		if s := student.(*Student); !s.IsActive || len(s.StatusTimeline()) != 1 {
+			t.Errorf("expected %s to be untouched by the dry runs", s.Name)
+		}
+	}
+}
+
+func TestBulkReportsEachStudentAndSkipsThoseItCannotChange(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register(Student{Name: "Alice", BirthYear: 2003})
+	registry.Register(Student{Name: "Bob", BirthYear: 2005, IsActive: true})
+	registry.Register(Student{Name: "Dana", BirthYear: time.Now().Year() - 14})
+
+	summary, err := registry.Bulk(BulkOperation{Action: BulkActivate, Filter: WithNames("Alice", "Bob", "Dana"), Actor: "registrar", Reason: "enrolled"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Bob is already active and Dana is a minor without guardian consent
+	if !reflect.DeepEqual(summary.Changed, []string{"Alice"}) || !reflect.DeepEqual(summary.Skipped, []string{"Bob", "Dana"}) {
+		t.Errorf("expected Alice changed and Bob and Dana skipped, got %+v", summary)
+	}
+	if summary.String() != "activate: changed 1 student [Alice], skipped 2 [Bob, Dana]" {
+		t.Errorf("unexpected description %q", summary.String())
+	}
+	alice, _ := registry.Find("Alice")
+	if change, ok := alice.LastStatusChange(true); !alice.IsActive || !ok || change.Reason != "enrolled" {
+		t.Errorf("expected Alice to be activated with the reason of the operation, got %+v", change)
+	}
+	if dana, _ := registry.Find("Dana"); dana.IsActive {
+		t.Error("expected Dana to stay inactive")
+	}
+
+	summary, err = registry.Bulk(BulkOperation{Action: BulkArchive, Filter: WithNames("Bob", "Dana"), Actor: "registrar", Reason: "end of term"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(summary.Changed, []string{"Dana"}) || !reflect.DeepEqual(summary.Skipped, []string{"Bob"}) {
+		t.Errorf("expected only the inactive Dana to be archived, got %+v", summary)
+	}
+	if len(registry.Snapshot()) != 2 || len(registry.Archived()) != 1 {
+		t.Errorf("expected Dana to move from the roster to the archive")
+	}
+}
+
+func TestBulkRequiresAFilter(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register(Student{Name: "Alice", BirthYear: 2003})
+
+	if _, err := registry.Bulk(BulkOperation{Action: BulkArchive}); !errors.Is(err, ErrNoFilter) {
+		t.Errorf("expected ErrNoFilter, got %v", err)
+	}
+	if _, err := registry.Preview(BulkOperation{Action: BulkArchive}); !errors.Is(err, ErrNoFilter) {
+		t.Errorf("expected ErrNoFilter from a preview, got %v", err)
+	}
+	if len(registry.Archived()) != 0 {
+		t.Error("expected nothing to be archived")
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// DefaultDuplicateThreshold is the score above which two records are
+// reported as probable duplicates
+const DefaultDuplicateThreshold = 0.8
+
+// Duplicate is a pair of records that probably belong to the same person
+type Duplicate struct {
+	First  Student
+	Second Student
+	Score  float64
+}
+
+// FindDuplicates returns the pairs of registered students whose duplicate
+// score is at least threshold, most likely duplicates first. The score
+// weighs the similarity of the names at 70% and the birth years at 30%.
+func (r *StudentRegistry) FindDuplicates(threshold float64) []Duplicate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var duplicates []Duplicate
+	for i, first := range r.students {
+		for _, second := range r.students[i+1:] {
+			score := duplicateScore(first, second)
+			if score >= threshold {
+				duplicates = append(duplicates, Duplicate{
+					First:  *copyStudent(first),
+					Second: *copyStudent(second),
+					Score:  score,
+				})
+			}
+		}
+	}
+	sort.SliceStable(duplicates, func(i, j int) bool { return duplicates[i].Score > duplicates[j].Score })
+	return duplicates
+}
+
+// Merge folds the record found by dropKey into the record found by keepKey
+// and removes it from the registry. The fields of the kept record win; the
+// guardians and status histories of both are kept, closed by an entry with
+// the status of the merged record, and the number of the dropped record
+// still finds the merged one. The consent of the dropped
+// record comes with its guardians when the kept record has none, and a
+// merged record that may not be active is deactivated.
+func (r *StudentRegistry) Merge(keepKey, dropKey string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keep, err := r.find(keepKey)
+	if err != nil {
+		return err
+	}
+	drop, err := r.find(dropKey)
+	if err != nil {
+		return err
+	}
+	if keep == drop {
+		return errors.New("cannot merge a student with itself")
+	}
+
+	for _, guardian := range drop.Guardians {
+		if !hasGuardian(keep, guardian.Name) {
+			keep.Guardians = append(keep.Guardians, guardian)
+		}
+	}
+	if keep.consent == ConsentNone {
+		keep.consent = drop.consent
+	}
+	keep.history = append(keep.history, drop.history...)
+	sort.SliceStable(keep.history, func(i, j int) bool { return keep.history[i].At.Before(keep.history[j].At) })
+	if keep.IsActive && keep.canActivate() != nil {
+		keep.setActive(false, mergeActor, "merged record lacks guardian consent")
+	} else {
+		// The dropped history may end in a status the kept record does not have
+		keep.history = append(keep.history, StatusChange{At: time.Now(), Actor: mergeActor, Reason: "merged a duplicate record", Active: keep.IsActive})
+	}
+	if drop.Number != "" {
+		keep.MergedNumbers = append(keep.MergedNumbers, drop.Number)
+	}
+	keep.MergedNumbers = append(keep.MergedNumbers, drop.MergedNumbers...)
+
+	for i, student := range r.students {
+		if student == drop {
+			r.students = append(r.students[:i], r.students[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+// Checks if a student already has a guardian with the given name
+func hasGuardian(s *Student, name string) bool {
+	for _, guardian := range s.Guardians {
+		if guardian.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns how likely two records belong to the same person, from 0 to 1
+func duplicateScore(a, b *Student) float64 {
+	yearScore := 0.0
+	switch diff := a.BirthYear - b.BirthYear; {
+	case diff == 0:
+		yearScore = 1
+	case diff == 1 || diff == -1:
+		yearScore = 0.5
+	}
+	return 0.7*nameSimilarity(a.Name, b.Name) + 0.3*yearScore
+}
+
+// Returns the similarity of two names from 0 to 1, ignoring case,
+// punctuation, extra spaces and the order of the words
+func nameSimilarity(a, b string) float64 {
+	a, b = normalizeName(a), normalizeName(b)
+	similarity := stringSimilarity(a, b)
+	if sorted := stringSimilarity(sortWords(a), sortWords(b)); sorted > similarity {
+		similarity = sorted
+	}
+	return similarity
+}
+
+// Lower-cases a name and keeps only letters, digits and single spaces
+func normalizeName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			return unicode.ToLower(r)
+		case unicode.IsSpace(r) || r == '-':
+			return ' '
+		default:
+			return -1
+		}
+	}, name)
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// Sorts the words of a name
+func sortWords(name string) string {
+	words := strings.Fields(name)
+	sort.Strings(words)
+	return strings.Join(words, " ")
+}
+
+// Returns 1 minus the edit distance divided by the length of the longer string
+func stringSimilarity(a, b string) float64 {
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(editDistance(ra, rb))/float64(longest)
+}
+
+// Returns the Levenshtein distance between two strings
+func editDistance(a, b []rune) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(b)]
+}
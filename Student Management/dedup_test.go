@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestFindDuplicatesAndMerge(t *testing.T) {
+	registry := NewStudentRegistry()
+	alice, _ := registry.Register(Student{Name: "Alice Smith", BirthYear: 2003, IsActive: true})
+	registry.Register(Student{Name: "Bob", BirthYear: 2005, IsActive: true})
+	copyNumber, _ := registry.Register(Student{Name: "smith, alice", BirthYear: 2003})
+	registry.AddGuardian(copyNumber, Guardian{Name: "Erin", Relationship: "mother", Contact: "erin@example.com"})
+	registry.Activate(copyNumber, "registrar", "imported")
+
+	duplicates := registry.FindDuplicates(DefaultDuplicateThreshold)
+	if len(duplicates) != 1 || duplicates[0].First.Number != alice || duplicates[0].Second.Number != copyNumber {
+		t.Fatalf("expected the two Alice records as the only duplicates, got %v", duplicates)
+	}
+
+	if err := registry.Merge(alice, copyNumber); err != nil {
+		t.Fatal(err)
+	}
+	if len(registry.Snapshot()) != 2 {
+		t.Errorf("expected 2 students after the merge, got %d", len(registry.Snapshot()))
+	}
+	merged, err := registry.Find(copyNumber)
+	if err != nil || merged.Number != alice {
+		t.Fatalf("expected the dropped number to find the merged record, got %v", merged)
+	}
+	// Both registrations, the activation of the copy and the merge
+	if len(merged.Guardians) != 1 || len(merged.StatusTimeline()) != 4 {
+		t.Errorf("expected the guardian and history of the dropped record, got %v and %v", merged.Guardians, merged.StatusTimeline())
+	}
+	if err := registry.Merge(alice, copyNumber); err == nil {
+		t.Error("expected merging a record with itself to fail")
+	}
+}
+
+func TestMergeEndsTheTimelineWithTheKeptStatus(t *testing.T) {
+	registry := NewStudentRegistry()
+	keep, _ := registry.Register(Student{Name: "Alice Smith", BirthYear: 2003, IsActive: true})
+	drop, _ := registry.Register(Student{Name: "Alice Smith", BirthYear: 2003, IsActive: true})
+	registry.Deactivate(drop, "registrar", "duplicate")
+
+	if err := registry.Merge(keep, drop); err != nil {
+		t.Fatal(err)
+	}
+	merged, _ := registry.Find(keep)
+	timeline := merged.StatusTimeline()
+	if last := timeline[len(timeline)-1]; !merged.IsActive || !last.Active || last.Actor != mergeActor {
+		t.Errorf("expected the timeline to end with the active merged record, got %v", timeline)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+// StudentFilter reports whether a student should be included
+type StudentFilter func(s *Student) bool
+
+// ActiveStudents matches active students
+func ActiveStudents(s *Student) bool {
+	return s.IsActive
+}
+
+// InactiveStudents matches inactive students
+func InactiveStudents(s *Student) bool {
+	return !s.IsActive
+}
+
+// AdultStudents matches adult students
+func AdultStudents(s *Student) bool {
+	return s.IsAdult()
+}
+
+// MinorStudents matches students under the adult age
+func MinorStudents(s *Student) bool {
+	return !s.IsAdult()
+}
+
+// OlderThan matches students older than the given age
+func OlderThan(age int) StudentFilter {
+	return func(s *Student) bool {
+		return s.GetAge() > age
+	}
+}
+
+// WithNames matches students with one of the given names
+func WithNames(names ...string) StudentFilter {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[name] = true
+	}
+	return func(s *Student) bool {
+		return set[s.Name]
+	}
+}
+
+// WithNumbers matches students with one of the given student numbers
+func WithNumbers(numbers ...string) StudentFilter {
+	set := make(map[string]bool, len(numbers))
+	for _, number := range numbers {
+		set[NormalizeStudentNumber(number)] = true
+	}
+	return func(s *Student) bool {
+		return s.Number != "" && set[s.Number]
+	}
+}
+
+// AllOf matches students that satisfy every filter
+func AllOf(filters ...StudentFilter) StudentFilter {
+	return func(s *Student) bool {
+		for _, filter := range filters {
+			if !filter(s) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Returns the students matched by the filter; a nil filter matches everyone
+func filterStudents(students []Person, filter StudentFilter) []Person {
+	var matched []Person
+	for _, student := range students {
+		if filter == nil || filter(student.(*Student)) {
+			matched = append(matched, student)
+		}
+	}
+	return matched
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrConsentRequired is returned when an action needs guardian consent first
+var ErrConsentRequired = errors.New("guardian consent required")
+
+// Guardian struct
+type Guardian struct {
+	Name         string
+	Relationship string
+	Contact      string
+}
+
+// ConsentState is the state of the guardian consent of a student
+type ConsentState int
+
+// Consent states
+const (
+	ConsentNone ConsentState = iota
+	ConsentRequested
+	ConsentGranted
+	ConsentRevoked
+)
+
+// String returns the name of the consent state
+func (c ConsentState) String() string {
+	switch c {
+	case ConsentNone:
+		return "none"
+	case ConsentRequested:
+		return "requested"
+	case ConsentGranted:
+		return "granted"
+	case ConsentRevoked:
+		return "revoked"
+	default:
+		return "unknown"
+	}
+}
+
+// Allowed consent transitions
+var consentTransitions = map[ConsentState][]ConsentState{
+	ConsentNone:      {ConsentRequested},
+	ConsentRequested: {ConsentGranted, ConsentRevoked},
+	ConsentGranted:   {ConsentRevoked},
+	ConsentRevoked:   {ConsentRequested},
+}
+
+// RequiresGuardian returns true while the student is under the adult age.
+// A student moves out of guardian-required status on turning 18.
+func (s *Student) RequiresGuardian() bool {
+	return !s.IsAdult()
+}
+
+// Consent returns the guardian consent state of the student
+func (s *Student) Consent() ConsentState {
+	return s.consent
+}
+
+// AddGuardian adds a guardian to the student
+func (s *Student) AddGuardian(guardian Guardian) error {
+	if guardian.Name == "" || guardian.Contact == "" {
+		return errors.New("guardian name and contact are required")
+	}
+	s.Guardians = append(s.Guardians, guardian)
+	return nil
+}
+
+// RequestConsent asks the guardians of the student for consent
+func (s *Student) RequestConsent() error {
+	if len(s.Guardians) == 0 {
+		return errors.New("student has no guardian to ask for consent")
+	}
+	return s.transitionConsent(ConsentRequested)
+}
+
+// GrantConsent records that a guardian granted consent
+func (s *Student) GrantConsent() error {
+	return s.transitionConsent(ConsentGranted)
+}
+
+// RevokeConsent records that a guardian revoked or declined consent
+func (s *Student) RevokeConsent() error {
+	return s.transitionConsent(ConsentRevoked)
+}
+
+// Moves the consent to a new state if the transition is allowed
+func (s *Student) transitionConsent(to ConsentState) error {
+	for _, allowed := range consentTransitions[s.consent] {
+		if allowed == to {
+			s.consent = to
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot change consent from %s to %s", s.consent, to)
+}
+
+// Checks if the student may be activated
+func (s *Student) canActivate() error {
+	if s.RequiresGuardian() && s.consent != ConsentGranted {
+		return ErrConsentRequired
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMinorNeedsConsentToActivate(t *testing.T) {
+	student := &Student{Name: "Dana", BirthYear: time.Now().Year() - 14}
+
+	if err := student.Activate("registrar", "test"); err != ErrConsentRequired {
+		t.Fatalf("expected ErrConsentRequired, got %v", err)
+	}
+
+	student.AddGuardian(Guardian{Name: "Erin", Relationship: "mother", Contact: "erin@example.com"})
+	if err := student.RequestConsent(); err != nil {
+		t.Fatal(err)
+	}
+	if err := student.GrantConsent(); err != nil {
+		t.Fatal(err)
+	}
+	if err := student.Activate("registrar", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if !student.IsActive {
+		t.Error("expected student to be active after consent was granted")
+	}
+}
+
+func TestConsentTransitions(t *testing.T) {
+	student := &Student{Name: "Dana", BirthYear: time.Now().Year() - 14}
+
+	if err := student.RequestConsent(); err == nil {
+		t.Error("expected error when requesting consent without a guardian, got nil")
+	}
+	student.AddGuardian(Guardian{Name: "Erin", Relationship: "mother", Contact: "erin@example.com"})
+	if err := student.GrantConsent(); err == nil {
+		t.Error("expected error when granting consent that was not requested, got nil")
+	}
+	student.RequestConsent()
+	student.RevokeConsent()
+	if student.Consent() != ConsentRevoked {
+		t.Errorf("expected consent to be revoked, got %s", student.Consent())
+	}
+}
+
+func TestAdultDoesNotNeedGuardian(t *testing.T) {
+	student := &Student{Name: "Frank", BirthYear: time.Now().Year() - adultAge}
+
+	if student.RequiresGuardian() {
+		t.Error("expected a student who turned 18 not to require a guardian")
+	}
+	if err := student.Activate("registrar", "test"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegisterChecksConsentOfActiveMinors(t *testing.T) {
+	registry := NewStudentRegistry()
+	minorYear := time.Now().Year() - 14
+
+	if _, err := registry.Register(Student{Name: "Dana", BirthYear: minorYear, IsActive: true}); err != ErrConsentRequired {
+		t.Fatalf("expected ErrConsentRequired for an active minor, got %v", err)
+	}
+	if len(registry.Snapshot()) != 0 {
+		t.Error("expected the active minor not to be registered")
+	}
+	if _, err := registry.Register(Student{Name: "Dana", BirthYear: minorYear}); err != nil {
+		t.Fatalf("expected an inactive minor to be registered, got %v", err)
+	}
+}
+
+func TestMergeKeepsConsent(t *testing.T) {
+	registry := NewStudentRegistry()
+	minorYear := time.Now().Year() - 14
+	keep, _ := registry.Register(Student{Name: "Dana Lee", BirthYear: minorYear})
+	drop, _ := registry.Register(Student{Name: "Lee, Dana", BirthYear: minorYear})
+	registry.AddGuardian(drop, Guardian{Name: "Erin", Relationship: "mother", Contact: "erin@example.com"})
+	registry.UpdateConsent(drop, ConsentRequested)
+	registry.UpdateConsent(drop, ConsentGranted)
+
+	if err := registry.Merge(keep, drop); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.Activate(keep, "registrar", "merged"); err != nil {
+		t.Errorf("expected the consent of the dropped record to allow activation, got %v", err)
+	}
+
+	// An active minor whose merged record has revoked consent is deactivated
+	other, _ := registry.Register(Student{Name: "Lee Dana", BirthYear: minorYear})
+	registry.UpdateConsent(keep, ConsentRevoked)
+	if err := registry.Merge(keep, other); err != nil {
+		t.Fatal(err)
+	}
+	merged, _ := registry.Find(keep)
+	if change, _ := merged.LastStatusChange(false); merged.IsActive || change.Actor != mergeActor {
+		t.Errorf("expected the merge to deactivate the minor without consent, got %v and %+v", merged.IsActive, change)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// Assignment struct
+type Assignment struct {
+	ID       int
+	Title    string
+	Due      time.Time
+	Students []*Student
+}
+
+// Submission struct
+type Submission struct {
+	AssignmentID  int
+	StudentNumber string
+	StudentName   string
+	SubmittedAt   time.Time
+	Late          bool
+	Graded        bool
+	Score         float64
+	Feedback      string
+}
+
+// HomeworkBook keeps assignments and the submissions made for them
+type HomeworkBook struct {
+	assignments map[int]*Assignment
+	submissions map[int]map[string]*Submission
+}
+
+// NewHomeworkBook creates a new HomeworkBook
+func NewHomeworkBook() *HomeworkBook {
+	return &HomeworkBook{
+		assignments: make(map[int]*Assignment),
+		submissions: make(map[int]map[string]*Submission),
+	}
+}
+
+// CreateAssignment creates an assignment for the active students of the group
+func (hb *HomeworkBook) CreateAssignment(title string, due time.Time, students []Person) *Assignment {
+	assignment := &Assignment{ID: len(hb.assignments) + 1, Title: title, Due: due}
+	for _, student := range students {
+		if s := student.(*Student); s.IsActive {
+			assignment.Students = append(assignment.Students, s)
+		}
+	}
+	hb.assignments[assignment.ID] = assignment
+	hb.submissions[assignment.ID] = make(map[string]*Submission)
+	return assignment
+}
+
+// Submit records a submission of a student by name or student number;
+// submitting again replaces the previous one and clears its grade
+func (hb *HomeworkBook) Submit(assignmentID int, key string, at time.Time) (*Submission, error) {
+	assignment, exists := hb.assignments[assignmentID]
+	if !exists {
+		return nil, errors.New("assignment not found")
+	}
+	student, err := rosterStudent(assignment.Students, key)
+	if err != nil {
+		return nil, err
+	}
+	if student == nil {
+		return nil, errors.New("assignment is not assigned to the student")
+	}
+	submission := &Submission{
+		AssignmentID:  assignmentID,
+		StudentNumber: student.Number,
+		StudentName:   student.Name,
+		SubmittedAt:   at,
+		Late:          at.After(assignment.Due),
+	}
+	hb.submissions[assignmentID][studentKey(student)] = submission
+	return submission, nil
+}
+
+// Grade attaches a score between 0 and 100 and feedback to the submission of
+// a student by name or student number
+func (hb *HomeworkBook) Grade(assignmentID int, key string, score float64, feedback string) error {
+	var submission *Submission
+	if assignment, exists := hb.assignments[assignmentID]; exists {
+		student, err := rosterStudent(assignment.Students, key)
+		if err != nil {
+			return err
+		}
+		if student != nil {
+			submission = hb.submissions[assignmentID][studentKey(student)]
+		}
+	}
+	if submission == nil {
+		return errors.New("submission not found")
+	}
+	if score < 0 || score > 100 {
+		return errors.New("score must be between 0 and 100")
+	}
+	submission.Graded = true
+	submission.Score = score
+	submission.Feedback = feedback
+	return nil
+}
+
+// Submissions returns the submissions of an assignment
+func (hb *HomeworkBook) Submissions(assignmentID int) []*Submission {
+	var submissions []*Submission
+	for _, submission := range hb.submissions[assignmentID] {
+		submissions = append(submissions, submission)
+	}
+	sort.Slice(submissions, func(i, j int) bool {
+		if !submissions[i].SubmittedAt.Equal(submissions[j].SubmittedAt) {
+			return submissions[i].SubmittedAt.Before(submissions[j].SubmittedAt)
+		}
+		return submissions[i].StudentNumber < submissions[j].StudentNumber
+	})
+	return submissions
+}
+
+// OutstandingWork returns the assignments of a student, by name or student
+// number, that have not been submitted yet, earliest due date first. A name
+// that more than one student of an assignment has is ambiguous.
+func (hb *HomeworkBook) OutstandingWork(key string) ([]*Assignment, error) {
+	var outstanding []*Assignment
+	for id, assignment := range hb.assignments {
+		student, err := rosterStudent(assignment.Students, key)
+		if err != nil {
+			return nil, err
+		}
+		if student == nil {
+			continue
+		}
+		if _, submitted := hb.submissions[id][studentKey(student)]; !submitted {
+			outstanding = append(outstanding, assignment)
+		}
+	}
+	sort.Slice(outstanding, func(i, j int) bool {
+		if !outstanding[i].Due.Equal(outstanding[j].Due) {
+			return outstanding[i].Due.Before(outstanding[j].Due)
+		}
+		return outstanding[i].ID < outstanding[j].ID
+	})
+	return outstanding, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSubmitFlagsLateWork(t *testing.T) {
+	alice := &Student{Number: "S1", Name: "Alice", IsActive: true}
+	bob := &Student{Number: "S2", Name: "Bob", IsActive: true}
+	book := NewHomeworkBook()
+	due := time.Date(2024, 10, 1, 23, 59, 0, 0, time.UTC)
+	essay := book.CreateAssignment("Essay", due, []Person{alice, bob})
+
+	onTime, err := book.Submit(essay.ID, "Alice", due)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if onTime.Late {
+		t.Error("expected a submission at the due time to be on time")
+	}
+	late, err := book.Submit(essay.ID, "S2", due.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !late.Late || late.StudentName != "Bob" {
+		t.Errorf("expected Bob's submission to be late, got %+v", late)
+	}
+
+	if err := book.Grade(essay.ID, "S2", 80, "Good"); err != nil {
+		t.Fatal(err)
+	}
+	resubmitted, err := book.Submit(essay.ID, "Bob", due.Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resubmitted.Late || resubmitted.Graded {
+		t.Errorf("expected resubmitting to replace the late, graded submission, got %+v", resubmitted)
+	}
+	submissions := book.Submissions(essay.ID)
+	if len(submissions) != 2 || submissions[0] != resubmitted {
+		t.Errorf("expected two submissions, earliest first, got %v", submissions)
+	}
+
+	if err := book.Grade(essay.ID, "Alice", 101, ""); err == nil {
+		t.Error("expected a score over 100 to be rejected")
+	}
+	if _, err := book.Submit(essay.ID, "Carol", due); err == nil {
+		t.Error("expected a student without the assignment to be rejected")
+	}
+	if _, err := book.Submit(99, "Alice", due); err == nil {
+		t.Error("expected an unknown assignment to be rejected")
+	}
+}
+
+func TestOutstandingWorkListsUnsubmittedAssignments(t *testing.T) {
+	alice := &Student{Number: "S1", Name: "Alice", IsActive: true}
+	carol := &Student{Number: "S3", Name: "Carol"}
+	book := NewHomeworkBook()
+	start := time.Date(2024, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	report := book.CreateAssignment("Report", start.AddDate(0, 0, 7), []Person{alice, carol})
+	quiz := book.CreateAssignment("Quiz", start, []Person{alice})
+	poem := book.CreateAssignment("Poem", start.AddDate(0, 0, 7), []Person{alice})
+	reading := book.CreateAssignment("Reading", start.AddDate(0, 0, 3), []Person{alice})
+	if len(report.Students) != 1 {
+		t.Fatalf("expected only the active student to get the assignment, got %d", len(report.Students))
+	}
+
+	titles := func(assignments []*Assignment, err error) []string {
+		if err != nil {
+			t.Fatal(err)
+		}
+		var list []string
+		for _, assignment := range assignments {
+			list = append(list, assignment.Title)
+		}
+		return list
+	}
+	want := []string{"Quiz", "Reading", "Report", "Poem"}
+	if got := titles(book.OutstandingWork("S1")); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if _, err := book.Submit(reading.ID, "Alice", start); err != nil {
+		t.Fatal(err)
+	}
+	// Late work is still handed in
+	if _, err := book.Submit(quiz.ID, "S1", start.AddDate(0, 0, 1)); err != nil {
+		t.Fatal(err)
+	}
+	want = []string{"Report", "Poem"}
+	if got := titles(book.OutstandingWork("Alice")); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v after submitting, got %v", want, got)
+	}
+	if _, err := book.Submit(poem.ID, "Alice", start); err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(book.OutstandingWork("Carol")); len(got) != 0 {
+		t.Errorf("expected nothing for a student without assignments, got %v", got)
+	}
+}
+
+func TestHomeworkRefusesAmbiguousNames(t *testing.T) {
+	first := &Student{Number: "S1", Name: "Alice", IsActive: true}
+	second := &Student{Number: "S2", Name: "Alice", IsActive: true}
+	book := NewHomeworkBook()
+	essay := book.CreateAssignment("Essay", time.Now(), []Person{first, second})
+
+	if _, err := book.Submit(essay.ID, "Alice", time.Now()); !errors.Is(err, ErrAmbiguousStudent) {
+		t.Errorf("expected an ambiguous submission to be refused, got %v", err)
+	}
+	if _, err := book.OutstandingWork("Alice"); !errors.Is(err, ErrAmbiguousStudent) {
+		t.Errorf("expected an ambiguous lookup to be refused, got %v", err)
+	}
+	if _, err := book.Submit(essay.ID, "S2", time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := book.Grade(essay.ID, "Alice", 90, ""); !errors.Is(err, ErrAmbiguousStudent) {
+		t.Errorf("expected an ambiguous grade to be refused, got %v", err)
+	}
+	if err := book.Grade(essay.ID, "S2", 90, ""); err != nil {
+		t.Error(err)
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -17,9 +21,16 @@ type Person interface {
 
 // Student struct
 type Student struct {
-	Name      string
-	BirthYear int
-	IsActive  bool
+	Number        string
+	Name          string
+	BirthYear     int
+	IsActive      bool
+	GradeLevel    int
+	HeldBack      bool
+	Guardians     []Guardian
+	MergedNumbers []string
+	consent       ConsentState
+	history       []StatusChange
 }
 
 // GetName returns the name of the student
@@ -38,64 +49,314 @@ func (s *Student) IsAdult() bool {
 	return s.GetAge() >= adultAge
 }
 
-// Activate activates the student
-func (s *Student) Activate() {
-	s.IsActive = true
+// Activate activates the student and records who did it and why. Students
+// under the adult age need guardian consent first.
+func (s *Student) Activate(actor, reason string) error {
+	if err := s.canActivate(); err != nil {
+		return err
+	}
+	s.setActive(true, actor, reason)
+	return nil
 }
 
-// Deactivate deactivates the student
-func (s *Student) Deactivate() {
-	s.IsActive = false
+// Deactivate deactivates the student and records who did it and why
+func (s *Student) Deactivate(actor, reason string) {
+	s.setActive(false, actor, reason)
 }
 
 func main() {
-	// Create a slice to hold registered students
-	var students []Person
+	lang := flag.String("lang", "", "language of the messages (en or es); defaults to LC_ALL, LC_MESSAGES or LANG")
+	flag.Parse()
+	translator = NewTranslator(SelectLocale(*lang))
+
+	// Create a registry to hold registered students
+	registry := NewStudentRegistry()
 
 	// Register students
-	students = append(students, &Student{Name: "Alice", BirthYear: 2003, IsActive: true})
-	students = append(students, &Student{Name: "Bob", BirthYear: 2005, IsActive: true})
-	students = append(students, &Student{Name: "Charlie", BirthYear: 1999, IsActive: false})
+	aliceNumber, _ := registry.Register(Student{Name: "Alice", BirthYear: 2003, IsActive: true, GradeLevel: 12})
+	registry.Register(Student{Name: "Bob", BirthYear: 2005, IsActive: true, GradeLevel: 11})
+	registry.Register(Student{Name: "Charlie", BirthYear: 1999, IsActive: false, GradeLevel: 12})
 
 	// Display registered students
+	students := registry.Snapshot()
 	displayStudents(students)
 
-	// Search for a student by name
-	student := findStudentByName(students, "Alice")
-	if student != nil {
-		fmt.Printf("\nFound student: %s, Age: %d, Active: %t, Adult: %t\n", student.GetName(), student.GetAge(), student.(*Student).IsActive, student.IsAdult())
+	// Search for a student by the number typed in by staff
+	typed := strings.ToLower(aliceNumber[:5] + "-" + aliceNumber[5:])
+	if err := registry.ValidateNumber(typed); err != nil {
+		fmt.Println("\n" + translator.T("students.invalid_number", typed, describeError(translator, err)))
+	} else if student, err := registry.Find(typed); err == nil {
+		fmt.Println("\n" + translator.T("students.found", student.GetName(), student.GetAge(),
+			translator.Bool(student.IsActive), translator.Bool(student.IsAdult())))
 	} else {
-		fmt.Println("\nStudent not found.")
+		fmt.Println("\n" + translator.T("students.not_found"))
+	}
+	// A wrong check digit is caught before any lookup
+	last := aliceNumber[len(aliceNumber)-1]
+	mistyped := aliceNumber[:len(aliceNumber)-1] + string('0'+(last-'0'+1)%10)
+	if err := registry.ValidateNumber(mistyped); err != nil {
+		fmt.Println(translator.T("students.invalid_number", mistyped, describeError(translator, err)))
 	}
 
 	// Deactivate a student
-	fmt.Println("\nDeactivating student Bob...")
-	deactivateStudent(students, "Bob")
+	fmt.Println("\n" + translator.T("students.deactivating", "Bob"))
+	if err := registry.Deactivate("Bob", "registrar", "withdrew from the course"); err != nil {
+		fmt.Println(translator.T("students.deactivate_fail", err))
+	}
+	students = registry.Snapshot()
 	displayStudents(students)
+	if bob, err := registry.Find("Bob"); err == nil {
+		displayStatusTimeline(&bob)
+	}
+
+	// Report the active students as Markdown, oldest first
+	fmt.Println("\n" + translator.T("students.active"))
+	RenderRoster(os.Stdout, students, ReportOptions{
+		Format:     MarkdownFormat,
+		Columns:    []Column{NameColumn, AgeColumn},
+		SortBy:     SortByAge,
+		Descending: true,
+		Filter:     ActiveStudents,
+		Translator: translator,
+	})
+
+	// Track attendance for the active students
+	book := NewAttendanceBook(75)
+	today := time.Now()
+	for day := 0; day < 4; day++ {
+		session := book.NewSession(today.AddDate(0, 0, day), students)
+		book.Record(session.ID, aliceNumber, Present)
+	}
+	book.Record(2, aliceNumber, Absent)
+	book.Record(3, aliceNumber, Absent)
+	displayAttendance(book, students, today, today.AddDate(0, 0, 3))
+
+	// Assign homework to the active students
+	homework := NewHomeworkBook()
+	essay := homework.CreateAssignment("Essay", today.AddDate(0, 0, -1), students)
+	homework.CreateAssignment("Lab report", today.AddDate(0, 0, 7), students)
+	homework.Submit(essay.ID, aliceNumber, today)
+	homework.Grade(essay.ID, aliceNumber, 85, "Good structure")
+	displayHomework(homework, essay, students, today)
+
+	// Build the weekly timetable
+	scheduler := &Scheduler{
+		Days:          []time.Weekday{time.Monday, time.Wednesday, time.Friday},
+		PeriodsPerDay: 2,
+		Rooms:         []Room{{Name: "Lab", Capacity: 2}, {Name: "Room 101", Capacity: 30}},
+		Courses: []Course{
+			{Name: "Math", Teacher: "Mr. Smith", SessionsPerWeek: 3, Students: enroll(registry, aliceNumber, "Bob")},
+			{Name: "Physics", Teacher: "Ms. Jones", SessionsPerWeek: 2, Students: enroll(registry, aliceNumber)},
+			{Name: "History", Teacher: "Mr. Smith", SessionsPerWeek: 2, Students: enroll(registry, "Bob", "Charlie")},
+		},
+	}
+	if timetable, err := scheduler.Generate(); err != nil {
+		fmt.Println(translator.T("timetable.failed", err))
+	} else {
+		displayTimetable(timetable, "Alice")
+	}
+
+	// End-of-term cleanup: reactivate Bob, deactivate students over 22 and archive inactive ones
+	fmt.Println()
+	summary, err := registry.Bulk(BulkOperation{Action: BulkActivate, Filter: WithNames("Bob"), Actor: "registrar", Reason: "re-enrolled"})
+	displayBulkSummary("bulk.applied", summary, err)
+	deactivateOlder := BulkOperation{Action: BulkDeactivate, Filter: OlderThan(22), Actor: "registrar", Reason: "end of term"}
+	summary, err = registry.Preview(deactivateOlder)
+	displayBulkSummary("bulk.preview", summary, err)
+	summary, err = registry.Bulk(deactivateOlder)
+	displayBulkSummary("bulk.applied", summary, err)
+	summary, err = registry.Bulk(BulkOperation{Action: BulkArchive, Filter: InactiveStudents, Actor: "registrar", Reason: "end of term"})
+	displayBulkSummary("bulk.applied", summary, err)
+	displayStudents(registry.Snapshot())
+
+	// Minors need guardian consent before they can be activated
+	registry.Register(Student{Name: "Dana", BirthYear: 2012, IsActive: false, GradeLevel: 8})
+	fmt.Println()
+	activateStudent(registry, "Dana")
+	registry.AddGuardian("Dana", Guardian{Name: "Erin", Relationship: "mother", Contact: "erin@example.com"})
+	registry.UpdateConsent("Dana", ConsentRequested)
+	registry.UpdateConsent("Dana", ConsentGranted)
+	activateStudent(registry, "Dana")
+	displayStudents(registry.Snapshot())
+
+	// Year-end rollover: Dana repeats the year, everyone else moves up
+	registry.Register(Student{Name: "Grace", BirthYear: 2008, IsActive: true, GradeLevel: 12})
+	registry.HoldBack("Dana")
+	fmt.Println("\n" + registry.PreviewRollover().Describe(translator))
+	fmt.Println(registry.Rollover("registrar", false).Describe(translator))
+	displayStudents(registry.Snapshot())
+
+	// An import registered Grace twice with a typo: merge the duplicate
+	registry.Register(Student{Name: "grace", BirthYear: 2008, IsActive: false, GradeLevel: 12})
+	registry.Register(Student{Name: "Gracie", BirthYear: 2008, IsActive: false, GradeLevel: 12})
+	mergeDuplicates(registry)
+	displayStudents(registry.Snapshot())
+
+	// Summarize the roster for the dashboard
+	stats := ComputeRosterStats(registry.Snapshot(), nil, 5)
+	fmt.Println("\n" + translator.T("stats.title"))
+	for _, format := range []ReportFormat{TextFormat, JSONFormat} {
+		if err := RenderStats(os.Stdout, stats, format, translator); err != nil {
+			fmt.Println(translator.T("stats.failed", err))
+		}
+	}
 }
 
-// Displays information about registered students
-func displayStudents(students []Person) {
-	fmt.Println("\nRegistered Students:")
+// Lists the probable duplicates and merges each newer record into the older one
+func mergeDuplicates(registry *StudentRegistry) {
+	duplicates := registry.FindDuplicates(DefaultDuplicateThreshold)
+	if len(duplicates) == 0 {
+		return
+	}
+	fmt.Println("\n" + translator.T("duplicates.title"))
+	for _, duplicate := range duplicates {
+		first, second := duplicate.First, duplicate.Second
+		fmt.Println(translator.T("duplicates.entry", first.Name, first.Number, first.BirthYear,
+			second.Name, second.Number, second.BirthYear, duplicate.Score))
+	}
+	for _, duplicate := range duplicates {
+		first, _ := registry.Find(duplicate.First.Number)
+		second, _ := registry.Find(duplicate.Second.Number)
+		if first.Number == second.Number {
+			// Already merged through an earlier pair
+			continue
+		}
+		keep, drop := first.Number, second.Number
+		if err := registry.Merge(keep, drop); err != nil {
+			fmt.Println(translator.T("duplicates.merge_fail", drop, keep, err))
+			continue
+		}
+		fmt.Println(translator.T("duplicates.merged", drop, keep))
+	}
+}
+
+// Looks up the students of a course by name or student number, leaving out
+// and reporting those that cannot be found
+func enroll(registry *StudentRegistry, keys ...string) []*Student {
+	var students []*Student
+	for _, key := range keys {
+		student, err := registry.Find(key)
+		if err != nil {
+			fmt.Println(translator.T("timetable.enroll_fail", key, err))
+			continue
+		}
+		students = append(students, &student)
+	}
+	return students
+}
+
+// Activates a student by name and reports the outcome
+func activateStudent(registry *StudentRegistry, name string) {
+	if err := registry.Activate(name, "registrar", "enrolled"); err != nil {
+		fmt.Println(translator.T("students.activate_fail", name, err))
+		return
+	}
+	fmt.Println(translator.T("students.activated", name))
+}
+
+// Displays the summary of a bulk operation, or why it failed
+func displayBulkSummary(key string, summary BulkSummary, err error) {
+	if err != nil {
+		fmt.Println(translator.T("bulk.failed", err))
+		return
+	}
+	fmt.Println(translator.T(key, summary.Describe(translator)))
+}
+
+// Displays the attendance percentage of each student and the flagged students
+func displayAttendance(book *AttendanceBook, students []Person, from, to time.Time) {
+	fmt.Println("\n" + translator.T("attendance.title"))
 	for _, student := range students {
-		fmt.Printf("Name: %s, Age: %d, Active: %t, Adult: %t\n", student.GetName(), student.GetAge(), student.(*Student).IsActive, student.IsAdult())
+		percentage, ok := book.AttendancePercentage(student.(*Student), from, to)
+		if !ok {
+			fmt.Println(translator.T("attendance.none", student.GetName()))
+			continue
+		}
+		fmt.Println(translator.T("attendance.entry", student.GetName(), percentage))
+	}
+	flagged := book.FlaggedStudents(from, to)
+	if len(flagged) > 0 {
+		var names []string
+		for _, student := range flagged {
+			names = append(names, student.GetName())
+		}
+		fmt.Println(translator.N("attendance.low", len(flagged), len(flagged), strings.Join(names, ", ")))
 	}
 }
 
-// Finds a student by name
-func findStudentByName(students []Person, name string) Person {
+// Displays the submissions of an assignment and the outstanding work of each student
+func displayHomework(homework *HomeworkBook, assignment *Assignment, students []Person, now time.Time) {
+	fmt.Println("\n" + translator.T("homework.submissions", assignment.Title))
+	for _, submission := range homework.Submissions(assignment.ID) {
+		fmt.Println(translator.T("homework.submission", submission.StudentName, translator.Date(submission.SubmittedAt),
+			translator.Bool(submission.Late), submission.Score, submission.Feedback))
+	}
+	fmt.Println("\n" + translator.T("homework.title"))
 	for _, student := range students {
-		if student.GetName() == name {
-			return student
+		outstanding, err := homework.OutstandingWork(student.(*Student).Number)
+		if err != nil {
+			fmt.Println(translator.T("homework.failed", student.GetName(), err))
+			continue
+		}
+		if len(outstanding) == 0 {
+			continue
+		}
+		fmt.Println(translator.N("homework.outstanding", len(outstanding), student.GetName(), len(outstanding)))
+		for _, assignment := range outstanding {
+			key := "homework.entry"
+			if assignment.Due.Before(now) {
+				key = "homework.overdue"
+			}
+			fmt.Println(translator.T(key, assignment.Title, translator.Date(assignment.Due)))
 		}
 	}
-	return nil
 }
 
-// Deactivates a student by name
-func deactivateStudent(students []Person, name string) {
-	student := findStudentByName(students, name)
-	if student != nil {
-		student.(*Student).Deactivate()
+// Displays the timetable of a student and the sessions that could not be scheduled
+func displayTimetable(timetable *Timetable, name string) {
+	fmt.Println("\n" + translator.T("timetable.title", name))
+	if err := RenderStudentTimetable(os.Stdout, timetable, name, TextFormat, translator); err != nil {
+		fmt.Println(translator.T("timetable.failed", err))
+	}
+	if timetable.Complete() {
+		return
+	}
+	fmt.Println(translator.N("timetable.conflicts", len(timetable.Conflicts), len(timetable.Conflicts)))
+	for _, conflict := range timetable.Conflicts {
+		fmt.Println(translator.T("conflict.session", conflict.Course, conflict.Session))
+		for _, reason := range conflict.Reasons {
+			switch reason.Kind {
+			case NoRoomLargeEnough:
+				fmt.Println(translator.T("conflict.no_room"))
+			case TeacherBusy:
+				fmt.Println(translator.N("conflict.teacher", reason.Slots, reason.Subject, reason.Slots))
+			case StudentBusy:
+				fmt.Println(translator.N("conflict.student", reason.Slots, reason.Subject, reason.Slots))
+			case RoomsTaken:
+				fmt.Println(translator.N("conflict.rooms", reason.Slots, reason.Slots))
+			case CourseMeetsThatDay:
+				fmt.Println(translator.N("conflict.day", reason.Slots, reason.Slots))
+			}
+		}
+	}
+}
+
+// Displays information about registered students
+func displayStudents(students []Person) {
+	fmt.Println("\n" + translator.T("students.title"))
+	if err := RenderRoster(os.Stdout, students, ReportOptions{Format: TextFormat, Translator: translator}); err != nil {
+		fmt.Println(translator.T("students.display_failed", err))
+		return
+	}
+	fmt.Println(translator.N("students.count", len(students), len(students)))
+}
+
+// Returns the message of an error in the locale of the translator when the
+// error carries a message key, and its English text otherwise
+func describeError(tr *Translator, err error) string {
+	var numberErr *NumberError
+	if errors.As(err, &numberErr) {
+		return numberErr.Describe(tr)
 	}
+	return err.Error()
 }
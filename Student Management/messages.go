@@ -0,0 +1,158 @@
+package main
+
+// translator formats the console messages; main selects its locale
+var translator = NewTranslator(DefaultLocale)
+
+// catalogs holds the messages of every supported locale
+var catalogs = map[Locale]map[string]message{
+	English: {
+		"bool.true":                {Other: "true"},
+		"bool.false":               {Other: "false"},
+		"column.Number":            {Other: "Number"},
+		"column.Name":              {Other: "Name"},
+		"column.Age":               {Other: "Age"},
+		"column.Active":            {Other: "Active"},
+		"column.Adult":             {Other: "Adult"},
+		"column.Grade":             {Other: "Grade"},
+		"column.Day":               {Other: "Day"},
+		"column.Period":            {Other: "Period"},
+		"column.Course":            {Other: "Course"},
+		"column.Teacher":           {Other: "Teacher"},
+		"column.Room":              {Other: "Room"},
+		"students.title":           {Other: "Registered Students:"},
+		"students.count":           {One: "%d student registered", Other: "%d students registered"},
+		"students.active":          {Other: "Active Students:"},
+		"students.found":           {Other: "Found student: %s, Age: %d, Active: %s, Adult: %s"},
+		"students.not_found":       {Other: "Student not found."},
+		"students.invalid_number":  {Other: "Invalid student number %s: %v"},
+		"number.prefix":            {Other: "must start with %q"},
+		"number.length":            {Other: "must have %d digits after the prefix"},
+		"number.digits":            {Other: "must only have digits after the prefix"},
+		"number.check_digit":       {Other: "has an invalid check digit"},
+		"students.display_failed":  {Other: "Could not display students: %v"},
+		"students.deactivating":    {Other: "Deactivating student %s..."},
+		"students.deactivate_fail": {Other: "Could not deactivate student: %v"},
+		"students.activated":       {Other: "Activated student %s"},
+		"students.activate_fail":   {Other: "Could not activate student %s: %v"},
+		"timeline.title":           {Other: "Status timeline of %s:"},
+		"timeline.entry":           {Other: "%s: Active: %s, By: %s, Reason: %s"},
+		"attendance.title":         {Other: "Attendance:"},
+		"attendance.entry":         {Other: "Name: %s, Attendance: %.1f%%"},
+		"attendance.none":          {Other: "Name: %s, Attendance: n/a"},
+		"attendance.low":           {One: "%d student with low attendance: %s", Other: "%d students with low attendance: %s"},
+		"homework.submissions":     {Other: "Submissions for %s:"},
+		"homework.submission":      {Other: "%s submitted on %s, Late: %s, Score: %.0f, Feedback: %s"},
+		"homework.title":           {Other: "Outstanding Work:"},
+		"homework.outstanding":     {One: "%s has %d outstanding assignment", Other: "%s has %d outstanding assignments"},
+		"homework.entry":           {Other: "  %s, due %s"},
+		"homework.overdue":         {Other: "  %s, due %s (overdue)"},
+		"homework.failed":          {Other: "Could not list the work of %s: %v"},
+		"bulk.preview":             {Other: "Preview: %s"},
+		"bulk.applied":             {Other: "Applied: %s"},
+		"bulk.failed":              {Other: "Bulk operation failed: %v"},
+		"bulk.changed":             {One: "%s: changed %d student", Other: "%s: changed %d students"},
+		"bulk.would_change":        {One: "%s: would change %d student", Other: "%s: would change %d students"},
+		"bulk.skipped":             {Other: ", skipped %d"},
+		"bulk.action.activate":     {Other: "activate"},
+		"bulk.action.deactivate":   {Other: "deactivate"},
+		"bulk.action.archive":      {Other: "archive"},
+		"timetable.title":          {Other: "Timetable of %s:"},
+		"timetable.failed":         {Other: "Could not build the timetable: %v"},
+		"timetable.enroll_fail":    {Other: "Could not enroll %s: %v"},
+		"timetable.conflicts":      {One: "%d session could not be scheduled:", Other: "%d sessions could not be scheduled:"},
+		"conflict.session":         {Other: "%s, session %d:"},
+		"conflict.no_room":         {Other: "  no room holds all of its students"},
+		"conflict.teacher":         {One: "  teacher %s is busy in %d slot", Other: "  teacher %s is busy in %d slots"},
+		"conflict.student":         {One: "  student %s is busy in %d slot", Other: "  student %s is busy in %d slots"},
+		"conflict.rooms":           {One: "  every suitable room is taken in %d slot", Other: "  every suitable room is taken in %d slots"},
+		"conflict.day":             {One: "  the course already meets on the day of %d slot", Other: "  the course already meets on the day of %d slots"},
+		"rollover.preview":         {Other: "Rollover preview: promote %d [%s], graduate %d [%s], hold back %d [%s], skip %d without a valid grade level [%s]"},
+		"rollover.applied":         {Other: "Rollover: promoted %d [%s], graduated %d [%s], held back %d [%s], skipped %d without a valid grade level [%s]"},
+		"duplicates.title":         {Other: "Probable duplicates:"},
+		"duplicates.entry":         {Other: "%s (%s, %d) and %s (%s, %d): score %.2f"},
+		"duplicates.merged":        {Other: "Merged %s into %s"},
+		"duplicates.merge_fail":    {Other: "Could not merge %s into %s: %v"},
+		"stats.title":              {Other: "Roster Statistics:"},
+		"stats.total":              {One: "%d student", Other: "%d students"},
+		"stats.active":             {Other: "Active: %d, Inactive: %d"},
+		"stats.adults":             {Other: "Adults: %d, Minors: %d"},
+		"stats.age":                {Other: "Mean age: %.1f, Median age: %.1f"},
+		"stats.histogram":          {Other: "Age distribution:"},
+		"stats.failed":             {Other: "Could not compute statistics: %v"},
+	},
+	Spanish: {
+		"bool.true":                {Other: "sí"},
+		"bool.false":               {Other: "no"},
+		"column.Number":            {Other: "Número"},
+		"column.Name":              {Other: "Nombre"},
+		"column.Age":               {Other: "Edad"},
+		"column.Active":            {Other: "Activo"},
+		"column.Adult":             {Other: "Adulto"},
+		"column.Grade":             {Other: "Curso"},
+		"column.Day":               {Other: "Día"},
+		"column.Period":            {Other: "Hora"},
+		"column.Course":            {Other: "Asignatura"},
+		"column.Teacher":           {Other: "Profesor"},
+		"column.Room":              {Other: "Aula"},
+		"students.title":           {Other: "Estudiantes registrados:"},
+		"students.count":           {One: "%d estudiante registrado", Other: "%d estudiantes registrados"},
+		"students.active":          {Other: "Estudiantes activos:"},
+		"students.found":           {Other: "Estudiante encontrado: %s, Edad: %d, Activo: %s, Adulto: %s"},
+		"students.not_found":       {Other: "Estudiante no encontrado."},
+		"students.invalid_number":  {Other: "Número de estudiante %s no válido: %v"},
+		"number.prefix":            {Other: "debe empezar por %q"},
+		"number.length":            {Other: "debe tener %d dígitos después del prefijo"},
+		"number.digits":            {Other: "solo puede tener dígitos después del prefijo"},
+		"number.check_digit":       {Other: "tiene un dígito de control no válido"},
+		"students.display_failed":  {Other: "No se pudieron mostrar los estudiantes: %v"},
+		"students.deactivating":    {Other: "Desactivando al estudiante %s..."},
+		"students.deactivate_fail": {Other: "No se pudo desactivar al estudiante: %v"},
+		"students.activated":       {Other: "Estudiante %s activado"},
+		"students.activate_fail":   {Other: "No se pudo activar al estudiante %s: %v"},
+		"timeline.title":           {Other: "Historial de estado de %s:"},
+		"timeline.entry":           {Other: "%s: Activo: %s, Por: %s, Motivo: %s"},
+		"attendance.title":         {Other: "Asistencia:"},
+		"attendance.entry":         {Other: "Nombre: %s, Asistencia: %.1f%%"},
+		"attendance.none":          {Other: "Nombre: %s, Asistencia: s/d"},
+		"attendance.low":           {One: "%d estudiante con baja asistencia: %s", Other: "%d estudiantes con baja asistencia: %s"},
+		"homework.submissions":     {Other: "Entregas de %s:"},
+		"homework.submission":      {Other: "%s entregó el %s, Tarde: %s, Nota: %.0f, Comentarios: %s"},
+		"homework.title":           {Other: "Trabajo pendiente:"},
+		"homework.outstanding":     {One: "%s tiene %d tarea pendiente", Other: "%s tiene %d tareas pendientes"},
+		"homework.entry":           {Other: "  %s, entrega el %s"},
+		"homework.overdue":         {Other: "  %s, entrega el %s (atrasada)"},
+		"homework.failed":          {Other: "No se pudo listar el trabajo de %s: %v"},
+		"bulk.preview":             {Other: "Vista previa: %s"},
+		"bulk.applied":             {Other: "Aplicado: %s"},
+		"bulk.failed":              {Other: "La operación masiva falló: %v"},
+		"bulk.changed":             {One: "%s: se modificó %d estudiante", Other: "%s: se modificaron %d estudiantes"},
+		"bulk.would_change":        {One: "%s: se modificaría %d estudiante", Other: "%s: se modificarían %d estudiantes"},
+		"bulk.skipped":             {Other: ", omitidos %d"},
+		"bulk.action.activate":     {Other: "activar"},
+		"bulk.action.deactivate":   {Other: "desactivar"},
+		"bulk.action.archive":      {Other: "archivar"},
+		"timetable.title":          {Other: "Horario de %s:"},
+		"timetable.failed":         {Other: "No se pudo crear el horario: %v"},
+		"timetable.enroll_fail":    {Other: "No se pudo matricular a %s: %v"},
+		"timetable.conflicts":      {One: "No se pudo programar %d sesión:", Other: "No se pudieron programar %d sesiones:"},
+		"conflict.session":         {Other: "%s, sesión %d:"},
+		"conflict.no_room":         {Other: "  ningún aula tiene capacidad para todos sus estudiantes"},
+		"conflict.teacher":         {One: "  el profesor %s está ocupado en %d hora", Other: "  el profesor %s está ocupado en %d horas"},
+		"conflict.student":         {One: "  el estudiante %s está ocupado en %d hora", Other: "  el estudiante %s está ocupado en %d horas"},
+		"conflict.rooms":           {One: "  todas las aulas adecuadas están ocupadas en %d hora", Other: "  todas las aulas adecuadas están ocupadas en %d horas"},
+		"conflict.day":             {One: "  la asignatura ya tiene clase el día de %d hora", Other: "  la asignatura ya tiene clase el día de %d horas"},
+		"rollover.preview":         {Other: "Vista previa del cambio de curso: promover %d [%s], graduar %d [%s], repetir %d [%s], omitir %d sin un curso válido [%s]"},
+		"rollover.applied":         {Other: "Cambio de curso: promovidos %d [%s], graduados %d [%s], repiten %d [%s], omitidos %d sin un curso válido [%s]"},
+		"duplicates.title":         {Other: "Posibles duplicados:"},
+		"duplicates.entry":         {Other: "%s (%s, %d) y %s (%s, %d): puntuación %.2f"},
+		"duplicates.merged":        {Other: "%s fusionado con %s"},
+		"duplicates.merge_fail":    {Other: "No se pudo fusionar %s con %s: %v"},
+		"stats.title":              {Other: "Estadísticas de la lista:"},
+		"stats.total":              {One: "%d estudiante", Other: "%d estudiantes"},
+		"stats.active":             {Other: "Activos: %d, Inactivos: %d"},
+		"stats.adults":             {Other: "Adultos: %d, Menores: %d"},
+		"stats.age":                {Other: "Edad media: %.1f, Edad mediana: %.1f"},
+		"stats.histogram":          {Other: "Distribución de edades:"},
+		"stats.failed":             {Other: "No se pudieron calcular las estadísticas: %v"},
+	},
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrStudentNotFound is returned when no registered student has the name or
+// student number of a lookup
+var ErrStudentNotFound = errors.New("student not found")
+
+// StudentRegistry struct
+type StudentRegistry struct {
+	students []*Student
+	archived []*Student
+	format   StudentNumberFormat
+	sequence int
+	mu       sync.RWMutex
+}
+
+// NewStudentRegistry creates a new StudentRegistry that numbers students with
+// the default format
+func NewStudentRegistry() *StudentRegistry {
+	return NewStudentRegistryWithFormat(DefaultStudentNumberFormat)
+}
+
+// NewStudentRegistryWithFormat creates a new StudentRegistry that numbers
+// students with the given format
+func NewStudentRegistryWithFormat(format StudentNumberFormat) *StudentRegistry {
+	return &StudentRegistry{format: format}
+}
+
+// Register adds a copy of the student to the registry and returns its
+// student number. Students without a number get the next free one; a number
+// given by the caller must be valid and unused. A student registered as
+// active must be allowed to be activated, so a minor needs guardian consent
+// first.
+func (r *StudentRegistry) Register(student Student) (string, error) {
+	if student.IsActive {
+		if err := student.canActivate(); err != nil {
+			return "", err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if student.Number != "" {
+		student.Number = NormalizeStudentNumber(student.Number)
+		if err := r.format.Validate(student.Number); err != nil {
+			return "", err
+		}
+		if r.numberTaken(student.Number) {
+			return "", fmt.Errorf("student number %s is already taken", student.Number)
+		}
+	} else {
+		number, err := r.nextNumber()
+		if err != nil {
+			return "", err
+		}
+		student.Number = number
+	}
+
+	student.Guardians = append([]Guardian(nil), student.Guardians...)
+	student.history = append([]StatusChange(nil), student.history...)
+	student.recordRegistration()
+	r.students = append(r.students, &student)
+	return student.Number, nil
+}
+
+// ValidateNumber checks a student number typed by staff against the format
+// of the registry
+func (r *StudentRegistry) ValidateNumber(number string) error {
+	return r.format.Validate(number)
+}
+
+// Returns the next unused student number; the caller must hold the lock
+func (r *StudentRegistry) nextNumber() (string, error) {
+	for {
+		r.sequence++
+		number, err := r.format.Number(r.sequence)
+		if err != nil {
+			return "", err
+		}
+		if !r.numberTaken(number) {
+			return number, nil
+		}
+	}
+}
+
+// Checks if a student number is used by a registered or archived student;
+// the caller must hold the lock
+func (r *StudentRegistry) numberTaken(number string) bool {
+	for _, students := range [][]*Student{r.students, r.archived} {
+		for _, student := range students {
+			if student.Number == number {
+				return true
+			}
+			for _, merged := range student.MergedNumbers {
+				if merged == number {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// Snapshot returns a copy of every registered student. Changes made to the
+// copies do not affect the registry.
+func (r *StudentRegistry) Snapshot() []Person {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	students := make([]Person, len(r.students))
+	for i, student := range r.students {
+		students[i] = copyStudent(student)
+	}
+	return students
+}
+
+// Find returns a copy of the student with the given student number, or else
+// of the only student with the given name. It returns ErrStudentNotFound when
+// no student matches and ErrAmbiguousStudent when several have the name.
+func (r *StudentRegistry) Find(key string) (Student, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	student, err := r.find(key)
+	if err != nil {
+		return Student{}, err
+	}
+	return *copyStudent(student), nil
+}
+
+// Activate activates a student by name or student number
+func (r *StudentRegistry) Activate(key, actor, reason string) error {
+	return r.update(key, func(s *Student) error { return s.Activate(actor, reason) })
+}
+
+// Deactivate deactivates a student by name or student number
+func (r *StudentRegistry) Deactivate(key, actor, reason string) error {
+	return r.update(key, func(s *Student) error {
+		s.Deactivate(actor, reason)
+		return nil
+	})
+}
+
+// AddGuardian adds a guardian to a student by name or student number
+func (r *StudentRegistry) AddGuardian(key string, guardian Guardian) error {
+	return r.update(key, func(s *Student) error { return s.AddGuardian(guardian) })
+}
+
+// UpdateConsent moves the guardian consent of a student by name or student
+// number to a new state
+func (r *StudentRegistry) UpdateConsent(key string, state ConsentState) error {
+	return r.update(key, func(s *Student) error {
+		switch state {
+		case ConsentRequested:
+			return s.RequestConsent()
+		case ConsentGranted:
+			return s.GrantConsent()
+		case ConsentRevoked:
+			return s.RevokeConsent()
+		default:
+			return s.transitionConsent(state)
+		}
+	})
+}
+
+// Applies a change to a student while holding the write lock
+func (r *StudentRegistry) update(key string, change func(s *Student) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	student, err := r.find(key)
+	if err != nil {
+		return err
+	}
+	return change(student)
+}
+
+// Finds a student by student number, or else by a name only one student has;
+// the caller must hold the lock
+func (r *StudentRegistry) find(key string) (*Student, error) {
+	student, err := rosterStudent(r.students, key)
+	if err == nil && student == nil {
+		err = ErrStudentNotFound
+	}
+	return student, err
+}
+
+// Returns a copy of a student that does not share its history
+func copyStudent(s *Student) *Student {
+	student := *s
+	student.Guardians = append([]Guardian(nil), s.Guardians...)
+	student.MergedNumbers = append([]string(nil), s.MergedNumbers...)
+	student.history = append([]StatusChange(nil), s.history...)
+	return &student
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRegistryConcurrentRegistrations(t *testing.T) {
+	registry := NewStudentRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			registry.Register(Student{Name: fmt.Sprintf("Student %d", i), BirthYear: 2000, IsActive: true})
+			registry.Snapshot()
+		}(i)
+	}
+	wg.Wait()
+
+	if students := registry.Snapshot(); len(students) != 50 {
+		t.Errorf("expected 50 students, got %d", len(students))
+	}
+}
+
+func TestRegistryConcurrentActivation(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register(Student{Name: "Alice", BirthYear: 2003, IsActive: true})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := registry.Deactivate("Alice", "registrar", "test"); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if err := registry.Activate("Alice", "registrar", "test"); err != nil {
+				t.Error(err)
+			}
+			if _, err := registry.Find("Alice"); err != nil {
+				t.Errorf("expected to find Alice, got %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	student, _ := registry.Find("Alice")
+	timeline := student.StatusTimeline()
+	for i := 1; i < len(timeline); i++ {
+		if timeline[i].Active == timeline[i-1].Active {
+			t.Fatalf("expected alternating status changes, got %v twice in a row", timeline[i].Active)
+		}
+	}
+}
+
+func TestRegistrySnapshotIsACopy(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register(Student{Name: "Bob", BirthYear: 2005, IsActive: true})
+
+	snapshot := registry.Snapshot()
+	snapshot[0].(*Student).Deactivate("registrar", "test")
+
+	student, _ := registry.Find("Bob")
+	if !student.IsActive {
+		t.Error("expected changes to a snapshot not to affect the registry")
+	}
+}
+
+func TestRegistryUnknownStudent(t *testing.T) {
+	registry := NewStudentRegistry()
+	if err := registry.Deactivate("Nobody", "registrar", "test"); err == nil {
+		t.Error("expected error when deactivating an unknown student, got nil")
+	}
+}
+
+func TestRegistryRefusesAmbiguousNames(t *testing.T) {
+	registry := NewStudentRegistry()
+	first, _ := registry.Register(Student{Name: "Alice", BirthYear: 2003, IsActive: true})
+	second, _ := registry.Register(Student{Name: "Alice", BirthYear: 2005, IsActive: true})
+
+	if err := registry.Deactivate("Alice", "registrar", "test"); !errors.Is(err, ErrAmbiguousStudent) {
+		t.Errorf("expected an ambiguous name to be refused, got %v", err)
+	}
+	if _, err := registry.Find("Alice"); !errors.Is(err, ErrAmbiguousStudent) {
+		t.Errorf("expected an ambiguous lookup to be refused, got %v", err)
+	}
+	if err := registry.Merge("Alice", second); !errors.Is(err, ErrAmbiguousStudent) {
+		t.Errorf("expected an ambiguous merge to be refused, got %v", err)
+	}
+	if _, err := registry.Find("Nobody"); !errors.Is(err, ErrStudentNotFound) {
+		t.Errorf("expected an unknown student to be reported, got %v", err)
+	}
+
+	if err := registry.Deactivate(second, "registrar", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if alice, _ := registry.Find(first); !alice.IsActive {
+		t.Error("expected the other Alice to stay active")
+	}
+	if alice, _ := registry.Find(second); alice.IsActive {
+		t.Error("expected the Alice with the number to be deactivated")
+	}
+}
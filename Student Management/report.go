@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// ReportFormat selects how a roster report is written
+type ReportFormat int
+
+// Report formats
+const (
+	TextFormat ReportFormat = iota
+	MarkdownFormat
+	CSVFormat
+	JSONFormat
+	HTMLFormat
+)
+
+// ParseReportFormat returns the report format with the given name
+func ParseReportFormat(name string) (ReportFormat, error) {
+	switch strings.ToLower(name) {
+	case "text", "txt":
+		return TextFormat, nil
+	case "markdown", "md":
+		return MarkdownFormat, nil
+	case "csv":
+		return CSVFormat, nil
+	case "json":
+		return JSONFormat, nil
+	case "html":
+		return HTMLFormat, nil
+	default:
+		return TextFormat, fmt.Errorf("unknown report format %q", name)
+	}
+}
+
+// Column is a column of a roster report
+type Column string
+
+// Report columns
+const (
+	NumberColumn Column = "Number"
+	NameColumn   Column = "Name"
+	AgeColumn    Column = "Age"
+	ActiveColumn Column = "Active"
+	AdultColumn  Column = "Adult"
+	GradeColumn  Column = "Grade"
+)
+
+// DefaultColumns are the columns used when none are chosen
+var DefaultColumns = []Column{NumberColumn, NameColumn, AgeColumn, GradeColumn, ActiveColumn, AdultColumn}
+
+// SortKey selects the order of the rows of a report
+type SortKey int
+
+// Sort keys
+const (
+	Unsorted SortKey = iota
+	SortByName
+	SortByAge
+)
+
+// ReportOptions configures a roster report
+type ReportOptions struct {
+	Format     ReportFormat
+	Columns    []Column
+	SortBy     SortKey
+	Descending bool
+	Filter     StudentFilter
+	Translator *Translator
+}
+
+// RenderRoster writes a report of the students to w. With a Translator the
+// headers and values of the text, Markdown and HTML formats are localized;
+// CSV and JSON stay the same in every locale.
+func RenderRoster(w io.Writer, students []Person, opts ReportOptions) error {
+	columns := opts.Columns
+	if len(columns) == 0 {
+		columns = DefaultColumns
+	}
+	for _, column := range columns {
+		if !knownColumn(column) {
+			return fmt.Errorf("unknown report column %q", column)
+		}
+	}
+
+	rows := filterStudents(students, opts.Filter)
+	sortStudents(rows, opts.SortBy, opts.Descending)
+
+	tr := opts.Translator
+	switch opts.Format {
+	case JSONFormat:
+		return renderJSON(w, rows, columns)
+	case CSVFormat:
+		tr = nil
+	}
+	header, cells := reportCells(rows, columns, tr)
+	return RenderTable(w, opts.Format, header, cells)
+}
+
+// RenderTable writes a table with the given header and cells to w. The JSON
+// format writes one object per row keyed by the lowercase header.
+func RenderTable(w io.Writer, format ReportFormat, header []string, cells [][]string) error {
+	switch format {
+	case TextFormat:
+		return renderText(w, header, cells)
+	case MarkdownFormat:
+		return renderMarkdown(w, header, cells)
+	case CSVFormat:
+		return renderCSV(w, header, cells)
+	case JSONFormat:
+		return renderJSONTable(w, header, cells)
+	case HTMLFormat:
+		return renderHTML(w, header, cells)
+	default:
+		return errors.New("unknown report format")
+	}
+}
+
+// Checks if a column can be rendered
+func knownColumn(column Column) bool {
+	for _, known := range DefaultColumns {
+		if column == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Sorts students in place by the given key
+func sortStudents(students []Person, key SortKey, descending bool) {
+	var less func(a, b Person) bool
+	switch key {
+	case SortByName:
+		less = func(a, b Person) bool { return a.GetName() < b.GetName() }
+	case SortByAge:
+		less = func(a, b Person) bool { return a.GetAge() < b.GetAge() }
+	default:
+		return
+	}
+	sort.SliceStable(students, func(i, j int) bool {
+		if descending {
+			return less(students[j], students[i])
+		}
+		return less(students[i], students[j])
+	})
+}
+
+// Returns the value of a column for a student
+func columnValue(student Person, column Column) interface{} {
+	switch column {
+	case NumberColumn:
+		return student.(*Student).Number
+	case NameColumn:
+		return student.GetName()
+	case AgeColumn:
+		return student.GetAge()
+	case ActiveColumn:
+		return student.(*Student).IsActive
+	case AdultColumn:
+		return student.IsAdult()
+	case GradeColumn:
+		return student.(*Student).GradeLevel
+	}
+	return nil
+}
+
+// Returns the value of a column for a student as text, localized when a
+// translator is given
+func columnText(student Person, column Column, tr *Translator) string {
+	switch value := columnValue(student, column).(type) {
+	case string:
+		return value
+	case int:
+		return strconv.Itoa(value)
+	case bool:
+		if tr != nil {
+			return tr.Bool(value)
+		}
+		return strconv.FormatBool(value)
+	}
+	return ""
+}
+
+// Returns the header and the text of each cell of the report
+func reportCells(students []Person, columns []Column, tr *Translator) ([]string, [][]string) {
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = string(column)
+		if tr != nil {
+			header[i] = tr.T("column." + string(column))
+		}
+	}
+	cells := make([][]string, len(students))
+	for i, student := range students {
+		cells[i] = make([]string, len(columns))
+		for j, column := range columns {
+			cells[i][j] = columnText(student, column, tr)
+		}
+	}
+	return header, cells
+}
+
+func renderText(w io.Writer, header []string, cells [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, strings.Join(header, "\t"))
+	for _, row := range cells {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func renderMarkdown(w io.Writer, header []string, cells [][]string) error {
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+	lines := []string{markdownRow(header), markdownRow(separator)}
+	for _, row := range cells {
+		lines = append(lines, markdownRow(row))
+	}
+	_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+	return err
+}
+
+// Formats a row of a Markdown table
+func markdownRow(cells []string) string {
+	escaped := make([]string, len(cells))
+	for i, cell := range cells {
+		escaped[i] = strings.ReplaceAll(cell, "|", `\|`)
+	}
+	return "| " + strings.Join(escaped, " | ") + " |"
+}
+
+func renderCSV(w io.Writer, header []string, cells [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(cells); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+func renderJSON(w io.Writer, students []Person, columns []Column) error {
+	rows := make([]map[string]interface{}, len(students))
+	for i, student := range students {
+		rows[i] = make(map[string]interface{}, len(columns))
+		for _, column := range columns {
+			rows[i][strings.ToLower(string(column))] = columnValue(student, column)
+		}
+	}
+	return writeJSON(w, rows)
+}
+
+func renderJSONTable(w io.Writer, header []string, cells [][]string) error {
+	rows := make([]map[string]string, len(cells))
+	for i, row := range cells {
+		rows[i] = make(map[string]string, len(header))
+		for j, name := range header {
+			rows[i][strings.ToLower(name)] = row[j]
+		}
+	}
+	return writeJSON(w, rows)
+}
+
+// Writes indented JSON
+func writeJSON(w io.Writer, data interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+var htmlReport = template.Must(template.New("roster").Parse(`<table>
+  <thead>
+    <tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr>
+  </thead>
+  <tbody>
+{{- range .Rows}}
+    <tr>{{range .}}<td>{{.}}</td>{{end}}</tr>
+{{- end}}
+  </tbody>
+</table>
+`))
+
+func renderHTML(w io.Writer, header []string, cells [][]string) error {
+	return htmlReport.Execute(w, struct {
+		Header []string
+		Rows   [][]string
+	}{header, cells})
+}
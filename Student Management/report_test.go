@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Students of the report tests, with ages that do not depend on the year
+func reportStudents() []Person {
+	year := time.Now().Year()
+	return []Person{
+		&Student{Number: "S2", Name: "Bob", BirthYear: year - 16, IsActive: true, GradeLevel: 11},
+		&Student{Number: "S1", Name: "Alice | A.", BirthYear: year - 20, IsActive: true, GradeLevel: 12},
+		&Student{Number: "S3", Name: "<Charlie>", BirthYear: year - 25, GradeLevel: 12},
+	}
+}
+
+func renderReport(t *testing.T, opts ReportOptions) string {
+	t.Helper()
+	var out bytes.Buffer
+	if err := RenderRoster(&out, reportStudents(), opts); err != nil {
+		t.Fatal(err)
+	}
+	return out.String()
+}
+
+func TestRenderRosterFormats(t *testing.T) {
+	columns := []Column{NameColumn, AgeColumn, ActiveColumn}
+	tests := []struct {
+		format ReportFormat
+		want   string
+	}{
+		{TextFormat, "Name        Age  Active\nBob         16   true\nAlice | A.  20   true\n<Charlie>   25   false\n"},
+		{MarkdownFormat, "| Name | Age | Active |\n| --- | --- | --- |\n| Bob | 16 | true |\n| Alice \\| A. | 20 | true |\n| <Charlie> | 25 | false |\n"},
+		{CSVFormat, "Name,Age,Active\nBob,16,true\nAlice | A.,20,true\n<Charlie>,25,false\n"},
+		{JSONFormat, `[
+  {
+    "active": true,
+    "age": 16,
+    "name": "Bob"
+  },
+  {
+    "active": true,
+    "age": 20,
+    "name": "Alice | A."
+  },
+  {
+    "active": false,
+    "age": 25,
+    "name": "\u003cCharlie\u003e"
+  }
+]
+`},
+		{HTMLFormat, `<table>
+  <thead>
+    <tr><th>Name</th><th>Age</th><th>Active</th></tr>
+  </thead>
+  <tbody>
+    <tr><td>Bob</td><td>16</td><td>true</td></tr>
+    <tr><td>Alice | A.</td><td>20</td><td>true</td></tr>
+    <tr><td>&lt;Charlie&gt;</td><td>25</td><td>false</td></tr>
+  </tbody>
+</table>
+`},
+	}
+	for _, test := range tests {
+		if got := renderReport(t, ReportOptions{Format: test.format, Columns: columns}); got != test.want {
+			t.Errorf("format %d: expected\n%s\ngot\n%s", test.format, test.want, got)
+		}
+	}
+}
+
+func TestRenderRosterLocalizesAllButCSVAndJSON(t *testing.T) {
+	tr := NewTranslator(Spanish)
+	columns := []Column{NameColumn, ActiveColumn}
+
+	text := renderReport(t, ReportOptions{Columns: columns, Translator: tr})
+	if !strings.HasPrefix(text, tr.T("column.Name")) || !strings.Contains(text, tr.Bool(true)) {
+		t.Errorf("expected a localized text report, got\n%s", text)
+	}
+	csv := renderReport(t, ReportOptions{Format: CSVFormat, Columns: columns, Translator: tr})
+	if !strings.HasPrefix(csv, "Name,Active\n") || !strings.Contains(csv, ",true\n") {
+		t.Errorf("expected CSV to stay the same in every locale, got\n%s", csv)
+	}
+	json := renderReport(t, ReportOptions{Format: JSONFormat, Columns: columns, Translator: tr})
+	if !strings.Contains(json, `"active": true`) {
+		t.Errorf("expected JSON to keep typed values, got\n%s", json)
+	}
+}
+
+func TestRenderRosterFiltersAndSorts(t *testing.T) {
+	names := func(opts ReportOptions) string {
+		opts.Format, opts.Columns = CSVFormat, []Column{NameColumn}
+		lines := strings.Split(strings.TrimSpace(renderReport(t, opts)), "\n")
+		return strings.Join(lines[1:], ",")
+	}
+	tests := []struct {
+		opts ReportOptions
+		want string
+	}{
+		{ReportOptions{}, "Bob,Alice | A.,<Charlie>"},
+		{ReportOptions{SortBy: SortByName}, "<Charlie>,Alice | A.,Bob"},
+		{ReportOptions{SortBy: SortByAge, Descending: true}, "<Charlie>,Alice | A.,Bob"},
+		{ReportOptions{Filter: ActiveStudents, SortBy: SortByAge}, "Bob,Alice | A."},
+		{ReportOptions{Filter: InactiveStudents}, "<Charlie>"},
+		{ReportOptions{Filter: AllOf(ActiveStudents, AdultStudents)}, "Alice | A."},
+		{ReportOptions{Filter: AllOf(MinorStudents, OlderThan(15))}, "Bob"},
+		{ReportOptions{Filter: OlderThan(16), SortBy: SortByName, Descending: true}, "Alice | A.,<Charlie>"},
+		{ReportOptions{Filter: WithNames("Bob", "<Charlie>"), SortBy: SortByAge}, "Bob,<Charlie>"},
+		{ReportOptions{Filter: WithNumbers("s3", "S1")}, "Alice | A.,<Charlie>"},
+		{ReportOptions{Filter: AllOf(ActiveStudents, InactiveStudents)}, ""},
+	}
+	for i, test := range tests {
+		if got := names(test.opts); got != test.want {
+			t.Errorf("case %d: expected %q, got %q", i, test.want, got)
+		}
+	}
+}
+
+func TestRenderRosterRejectsUnknownColumnsAndFormats(t *testing.T) {
+	var out bytes.Buffer
+	if err := RenderRoster(&out, reportStudents(), ReportOptions{Columns: []Column{"Email"}}); err == nil {
+		t.Error("expected an error for an unknown column")
+	}
+	if _, err := ParseReportFormat("pdf"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+	if format, err := ParseReportFormat("MD"); err != nil || format != MarkdownFormat {
+		t.Errorf("expected md to name the Markdown format, got %v, %v", format, err)
+	}
+}
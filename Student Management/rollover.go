@@ -0,0 +1,91 @@
+package main
+
+import "strings"
+
+// finalGradeLevel is the last grade level before graduation
+const finalGradeLevel = 12
+
+// RolloverReport lists what an academic year rollover changes, and the
+// active students it skipped for lacking a valid grade level
+type RolloverReport struct {
+	DryRun    bool
+	Promoted  []string
+	Graduated []string
+	HeldBack  []string
+	Skipped   []string
+}
+
+// String returns a readable summary of the rollover in English
+func (rr RolloverReport) String() string {
+	return rr.Describe(NewTranslator(English))
+}
+
+// Describe returns a readable summary of the rollover in the locale of the translator
+func (rr RolloverReport) Describe(tr *Translator) string {
+	key := "rollover.applied"
+	if rr.DryRun {
+		key = "rollover.preview"
+	}
+	return tr.T(key,
+		len(rr.Promoted), strings.Join(rr.Promoted, ", "),
+		len(rr.Graduated), strings.Join(rr.Graduated, ", "),
+		len(rr.HeldBack), strings.Join(rr.HeldBack, ", "),
+		len(rr.Skipped), strings.Join(rr.Skipped, ", "))
+}
+
+// HoldBack flags a student by name or student number to stay in the same
+// grade level at the next rollover
+func (r *StudentRegistry) HoldBack(key string) error {
+	return r.update(key, func(s *Student) error {
+		s.HeldBack = true
+		return nil
+	})
+}
+
+// PreviewRollover reports what the academic year rollover would change
+// without applying it
+func (r *StudentRegistry) PreviewRollover() RolloverReport {
+	return r.Rollover("", true)
+}
+
+// Rollover promotes every active student to the next grade level and
+// graduates and deactivates those in the final level. Students flagged as
+// held back stay where they are and lose the flag. Active students without a
+// valid grade level are skipped and listed in the report, so staff can fix
+// them without holding up everyone else. The rollover runs on copies of the
+// students, which replace the registry unless it is a dry run.
+func (r *StudentRegistry) Rollover(actor string, dryRun bool) RolloverReport {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	report := RolloverReport{DryRun: dryRun}
+	next := make([]*Student, len(r.students))
+	for i, current := range r.students {
+		student := copyStudent(current)
+		next[i] = student
+		if !student.IsActive {
+			continue
+		}
+		if student.GradeLevel < 1 || student.GradeLevel > finalGradeLevel {
+			report.Skipped = append(report.Skipped, student.Name)
+			continue
+		}
+
+		switch {
+		case student.HeldBack:
+			student.HeldBack = false
+			report.HeldBack = append(report.HeldBack, student.Name)
+		case student.GradeLevel == finalGradeLevel:
+			student.Deactivate(actor, "graduated")
+			report.Graduated = append(report.Graduated, student.Name)
+		default:
+			student.GradeLevel++
+			report.Promoted = append(report.Promoted, student.Name)
+		}
+	}
+
+	if !dryRun {
+		r.students = next
+	}
+	return report
+}
@@ -0,0 +1,58 @@
+package main
+
+import "testing"
+
+func TestRolloverPromotesGraduatesAndHoldsBack(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register(Student{Name: "Alice", BirthYear: 2003, IsActive: true, GradeLevel: 12})
+	registry.Register(Student{Name: "Bob", BirthYear: 2005, IsActive: true, GradeLevel: 10})
+	registry.Register(Student{Name: "Charlie", BirthYear: 2005, IsActive: true, GradeLevel: 10, HeldBack: true})
+
+	report := registry.Rollover("registrar", false)
+	if len(report.Promoted) != 1 || len(report.Graduated) != 1 || len(report.HeldBack) != 1 {
+		t.Errorf("expected 1 promoted, 1 graduated and 1 held back, got %v", report)
+	}
+
+	alice, _ := registry.Find("Alice")
+	if alice.IsActive {
+		t.Error("expected graduated student to be deactivated")
+	}
+	bob, _ := registry.Find("Bob")
+	if bob.GradeLevel != 11 {
+		t.Errorf("expected Bob in grade 11, got %d", bob.GradeLevel)
+	}
+	charlie, _ := registry.Find("Charlie")
+	if charlie.GradeLevel != 10 || charlie.HeldBack {
+		t.Errorf("expected Charlie to stay in grade 10 without the held back flag, got %d, %t", charlie.GradeLevel, charlie.HeldBack)
+	}
+}
+
+func TestRolloverSkipsStudentsWithoutAGradeLevel(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register(Student{Name: "Alice", BirthYear: 2003, IsActive: true, GradeLevel: 12})
+	registry.Register(Student{Name: "Bob", BirthYear: 2005, IsActive: true})
+
+	report := registry.Rollover("registrar", false)
+	if len(report.Graduated) != 1 || len(report.Skipped) != 1 || report.Skipped[0] != "Bob" {
+		t.Errorf("expected Alice to graduate and Bob to be skipped, got %v", report)
+	}
+
+	alice, _ := registry.Find("Alice")
+	bob, _ := registry.Find("Bob")
+	if alice.IsActive || !bob.IsActive || bob.GradeLevel != 0 {
+		t.Errorf("expected only Alice to change, got %v and %v", alice, bob)
+	}
+}
+
+func TestPreviewRolloverChangesNothing(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register(Student{Name: "Bob", BirthYear: 2005, IsActive: true, GradeLevel: 10})
+
+	report := registry.PreviewRollover()
+	if len(report.Promoted) != 1 {
+		t.Errorf("expected 1 student to be promoted, got %d", len(report.Promoted))
+	}
+	if bob, _ := registry.Find("Bob"); bob.GradeLevel != 10 {
+		t.Errorf("expected preview not to change the grade level, got %d", bob.GradeLevel)
+	}
+}
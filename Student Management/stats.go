@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// AgeBucket counts the students whose age is between From and To inclusive
+type AgeBucket struct {
+	From  int `json:"from"`
+	To    int `json:"to"`
+	Count int `json:"count"`
+}
+
+// RosterStats summarizes a group of students
+type RosterStats struct {
+	Total     int         `json:"total"`
+	Active    int         `json:"active"`
+	Inactive  int         `json:"inactive"`
+	Adults    int         `json:"adults"`
+	Minors    int         `json:"minors"`
+	MeanAge   float64     `json:"mean_age"`
+	MedianAge float64     `json:"median_age"`
+	Ages      []AgeBucket `json:"age_histogram"`
+}
+
+// ComputeRosterStats summarizes the students that match filter, which may be
+// nil. The age histogram groups ages in buckets of bucketWidth years and
+// leaves out empty buckets.
+func ComputeRosterStats(students []Person, filter StudentFilter, bucketWidth int) RosterStats {
+	if bucketWidth < 1 {
+		bucketWidth = 1
+	}
+	rows := filterStudents(students, filter)
+	stats := RosterStats{Total: len(rows), Ages: []AgeBucket{}}
+	if len(rows) == 0 {
+		return stats
+	}
+
+	ages := make([]int, 0, len(rows))
+	buckets := make(map[int]int)
+	sum := 0
+	for _, student := range rows {
+		if student.(*Student).IsActive {
+			stats.Active++
+		} else {
+			stats.Inactive++
+		}
+		if student.IsAdult() {
+			stats.Adults++
+		} else {
+			stats.Minors++
+		}
+		age := student.GetAge()
+		ages = append(ages, age)
+		sum += age
+		buckets[floorDiv(age, bucketWidth)]++
+	}
+
+	sort.Ints(ages)
+	stats.MeanAge = float64(sum) / float64(len(ages))
+	if middle := len(ages) / 2; len(ages)%2 == 1 {
+		stats.MedianAge = float64(ages[middle])
+	} else {
+		stats.MedianAge = float64(ages[middle-1]+ages[middle]) / 2
+	}
+
+	for bucket, count := range buckets {
+		from := bucket * bucketWidth
+		stats.Ages = append(stats.Ages, AgeBucket{From: from, To: from + bucketWidth - 1, Count: count})
+	}
+	sort.Slice(stats.Ages, func(i, j int) bool { return stats.Ages[i].From < stats.Ages[j].From })
+	return stats
+}
+
+// RenderStats writes the statistics to w as text, localized when a
+// translator is given, or as JSON
+func RenderStats(w io.Writer, stats RosterStats, format ReportFormat, tr *Translator) error {
+	switch format {
+	case JSONFormat:
+		return writeJSON(w, stats)
+	case TextFormat:
+		if tr == nil {
+			tr = NewTranslator(DefaultLocale)
+		}
+		lines := []string{
+			tr.N("stats.total", stats.Total, stats.Total),
+			tr.T("stats.active", stats.Active, stats.Inactive),
+			tr.T("stats.adults", stats.Adults, stats.Minors),
+			tr.T("stats.age", stats.MeanAge, stats.MedianAge),
+			tr.T("stats.histogram"),
+		}
+		for _, bucket := range stats.Ages {
+			label := fmt.Sprintf("%d-%d", bucket.From, bucket.To)
+			if bucket.From == bucket.To {
+				label = fmt.Sprint(bucket.From)
+			}
+			lines = append(lines, fmt.Sprintf("  %-7s %s %d", label, strings.Repeat("#", bucket.Count), bucket.Count))
+		}
+		_, err := fmt.Fprintln(w, strings.Join(lines, "\n"))
+		return err
+	default:
+		return errors.New("statistics are only available as text or JSON")
+	}
+}
+
+// Divides rounding toward negative infinity
+func floorDiv(a, b int) int {
+	if a < 0 && a%b != 0 {
+		return a/b - 1
+	}
+	return a / b
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeRosterStats(t *testing.T) {
+	year := time.Now().Year()
+	students := []Person{
+		&Student{Name: "Alice", BirthYear: year - 20, IsActive: true},
+		&Student{Name: "Bob", BirthYear: year - 22, IsActive: true},
+		&Student{Name: "Charlie", BirthYear: year - 16},
+		&Student{Name: "Dana", BirthYear: year - 12, IsActive: true},
+	}
+
+	stats := ComputeRosterStats(students, nil, 5)
+	if stats.Total != 4 || stats.Active != 3 || stats.Inactive != 1 || stats.Adults != 2 || stats.Minors != 2 {
+		t.Errorf("unexpected counts: %+v", stats)
+	}
+	if stats.MeanAge != 17.5 || stats.MedianAge != 18 {
+		t.Errorf("expected mean 17.5 and median 18, got %v and %v", stats.MeanAge, stats.MedianAge)
+	}
+	expected := []AgeBucket{{10, 14, 1}, {15, 19, 1}, {20, 24, 2}}
+	if len(stats.Ages) != len(expected) {
+		t.Fatalf("expected buckets %v, got %v", expected, stats.Ages)
+	}
+	for i, bucket := range expected {
+		if stats.Ages[i] != bucket {
+			t.Errorf("expected bucket %v, got %v", bucket, stats.Ages[i])
+		}
+	}
+
+	var out strings.Builder
+	if err := RenderStats(&out, ComputeRosterStats(students, ActiveStudents, 5), JSONFormat, nil); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"total": 3`) || !strings.Contains(out.String(), `"age_histogram"`) {
+		t.Errorf("unexpected JSON: %s", out.String())
+	}
+}
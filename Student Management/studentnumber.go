@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// StudentNumberFormat configures generated student numbers. A number is the
+// prefix, the four-digit year, a zero-padded sequence and a Luhn check digit,
+// for example S2026000017.
+type StudentNumberFormat struct {
+	Prefix string
+	Year   int
+	Digits int
+}
+
+// DefaultStudentNumberFormat is used by NewStudentRegistry
+var DefaultStudentNumberFormat = StudentNumberFormat{Prefix: "S", Digits: 5}
+
+// Returns the year of the format, the current year when it is not set
+func (f StudentNumberFormat) year() int {
+	if f.Year == 0 {
+		return time.Now().Year()
+	}
+	return f.Year
+}
+
+// Returns the width of the sequence, five digits when it is not set
+func (f StudentNumberFormat) digits() int {
+	if f.Digits <= 0 {
+		return 5
+	}
+	return f.Digits
+}
+
+// Number returns the student number with the given sequence
+func (f StudentNumberFormat) Number(sequence int) (string, error) {
+	body := fmt.Sprintf("%04d%0*d", f.year(), f.digits(), sequence)
+	if len(body) != 4+f.digits() {
+		return "", fmt.Errorf("sequence %d does not fit in %d digits", sequence, f.digits())
+	}
+	return strings.ToUpper(f.Prefix) + body + strconv.Itoa(luhnCheckDigit(body)), nil
+}
+
+// NumberError explains why a student number is not valid, by a message key
+// that is translated when the error is shown
+type NumberError struct {
+	Key  string
+	Args []interface{}
+}
+
+// Error describes the problem in English
+func (e *NumberError) Error() string {
+	return "student number " + e.Describe(NewTranslator(English))
+}
+
+// Describe returns the problem in the locale of the translator
+func (e *NumberError) Describe(tr *Translator) string {
+	return tr.T(e.Key, e.Args...)
+}
+
+// Validate checks that a number has the prefix and length of the format and
+// a valid check digit. The year is not checked, so numbers issued in earlier
+// years stay valid. The error is a *NumberError.
+func (f StudentNumberFormat) Validate(number string) error {
+	number = NormalizeStudentNumber(number)
+	prefix := strings.ToUpper(f.Prefix)
+	if !strings.HasPrefix(number, prefix) {
+		return &NumberError{Key: "number.prefix", Args: []interface{}{prefix}}
+	}
+	digits := number[len(prefix):]
+	if len(digits) != 4+f.digits()+1 {
+		return &NumberError{Key: "number.length", Args: []interface{}{4 + f.digits() + 1}}
+	}
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return &NumberError{Key: "number.digits"}
+		}
+	}
+	body, check := digits[:len(digits)-1], int(digits[len(digits)-1]-'0')
+	if luhnCheckDigit(body) != check {
+		return &NumberError{Key: "number.check_digit"}
+	}
+	return nil
+}
+
+// NormalizeStudentNumber removes spaces and dashes typed by staff and
+// upper-cases the prefix
+func NormalizeStudentNumber(number string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) || r == '-' {
+			return -1
+		}
+		return unicode.ToUpper(r)
+	}, number)
+}
+
+// Returns the Luhn check digit of a string of digits
+func luhnCheckDigit(digits string) int {
+	sum := 0
+	double := true
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return (10 - sum%10) % 10
+}
+
+// Checks if a student matches a lookup key, which is either the student
+// number, the number of a record merged into it, or the name
+func matchesStudent(s *Student, key string) bool {
+	number := NormalizeStudentNumber(key)
+	if s.Number != "" && s.Number == number {
+		return true
+	}
+	for _, merged := range s.MergedNumbers {
+		if merged == number {
+			return true
+		}
+	}
+	return s.Name == key
+}
+
+// Returns the key that identifies a student in records: the student number,
+// or the name for students without one
+func studentKey(s *Student) string {
+	if s.Number != "" {
+		return s.Number
+	}
+	return s.Name
+}
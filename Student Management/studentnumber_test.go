@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestStudentNumbersAreUniqueAndValid(t *testing.T) {
+	registry := NewStudentRegistryWithFormat(StudentNumberFormat{Prefix: "T", Year: 2024, Digits: 3})
+	first, err := registry.Register(Student{Name: "Alice", BirthYear: 2003, IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := registry.Register(Student{Name: "Alice", BirthYear: 2005, IsActive: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first != "T20240016" || first == second {
+		t.Errorf("expected T20240016 and a different second number, got %s and %s", first, second)
+	}
+	if err := registry.ValidateNumber("t-2024-001-6"); err != nil {
+		t.Errorf("expected typed number to be valid, got %v", err)
+	}
+	if err := registry.ValidateNumber("T20240017"); err == nil {
+		t.Error("expected wrong check digit to be rejected")
+	}
+	if _, err := registry.Register(Student{Number: first, Name: "Bob", BirthYear: 2005}); err == nil {
+		t.Error("expected duplicate number to be rejected")
+	}
+
+	student, err := registry.Find(second)
+	if err != nil || student.BirthYear != 2005 {
+		t.Errorf("expected lookup by number to find the second Alice, got %v", student)
+	}
+}
+
+func TestNumberErrorsAreTranslated(t *testing.T) {
+	registry := NewStudentRegistryWithFormat(StudentNumberFormat{Prefix: "T", Year: 2024, Digits: 3})
+
+	var numberErr *NumberError
+	err := registry.ValidateNumber("T20240017")
+	if !errors.As(err, &numberErr) {
+		t.Fatalf("expected a NumberError, got %v", err)
+	}
+	if got := numberErr.Describe(NewTranslator(Spanish)); got != "tiene un dígito de control no válido" {
+		t.Errorf("expected the Spanish message, got %q", got)
+	}
+	if err.Error() != "student number has an invalid check digit" {
+		t.Errorf("expected the English error text, got %q", err.Error())
+	}
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// maxSearchSteps bounds the backtracking search of the scheduler
+const maxSearchSteps = 200000
+
+// TimeSlot is a teaching period on a day of the week
+type TimeSlot struct {
+	Day    time.Weekday
+	Period int
+}
+
+// Room struct
+type Room struct {
+	Name     string
+	Capacity int
+}
+
+// Course struct. Students are told apart by student number, so two students
+// with the same name are booked separately.
+type Course struct {
+	Name            string
+	Teacher         string
+	SessionsPerWeek int
+	Students        []*Student
+}
+
+// ScheduledSession is a course meeting placed in a room and a time slot
+type ScheduledSession struct {
+	Course   string
+	Teacher  string
+	Room     string
+	Slot     TimeSlot
+	Students []*Student
+}
+
+// ConflictKind tells which constraint kept a session from being scheduled
+type ConflictKind int
+
+// Conflict kinds
+const (
+	NoRoomLargeEnough ConflictKind = iota
+	TeacherBusy
+	StudentBusy
+	RoomsTaken
+	CourseMeetsThatDay
+)
+
+// ConflictReason is a constraint that blocked a session in some time slots
+type ConflictReason struct {
+	Kind    ConflictKind
+	Subject string
+	Slots   int
+}
+
+// Conflict describes a course session that could not be scheduled
+type Conflict struct {
+	Course  string
+	Session int
+	Reasons []ConflictReason
+}
+
+// Timetable struct
+type Timetable struct {
+	Sessions  []ScheduledSession
+	Conflicts []Conflict
+}
+
+// Complete returns true if every session was scheduled
+func (t *Timetable) Complete() bool {
+	return len(t.Conflicts) == 0
+}
+
+// ForStudent returns the sessions a student, by name or student number,
+// attends, in weekly order. A name that more than one student of the
+// timetable has is ambiguous.
+func (t *Timetable) ForStudent(key string) ([]ScheduledSession, error) {
+	var roster []*Student
+	seen := make(map[string]bool)
+	for _, session := range t.Sessions {
+		for _, student := range session.Students {
+			if !seen[studentKey(student)] {
+				seen[studentKey(student)] = true
+				roster = append(roster, student)
+			}
+		}
+	}
+	found, err := rosterStudent(roster, key)
+	if err != nil || found == nil {
+		return nil, err
+	}
+
+	var sessions []ScheduledSession
+	for _, session := range t.Sessions {
+		for _, student := range session.Students {
+			if studentKey(student) == studentKey(found) {
+				sessions = append(sessions, session)
+				break
+			}
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		a, b := sessions[i].Slot, sessions[j].Slot
+		if a.Day != b.Day {
+			return weekdayOrder(a.Day) < weekdayOrder(b.Day)
+		}
+		return a.Period < b.Period
+	})
+	return sessions, nil
+}
+
+// Scheduler assigns weekly time slots and rooms to courses so that no
+// student, teacher or room is double-booked and a course meets at most once
+// a day
+type Scheduler struct {
+	Days          []time.Weekday
+	PeriodsPerDay int
+	Rooms         []Room
+	Courses       []Course
+}
+
+// Generate builds a timetable. When no complete timetable is found within
+// maxSearchSteps the result holds the sessions that could be placed and a
+// conflict for every other one.
+func (s *Scheduler) Generate() (*Timetable, error) {
+	if len(s.Days) == 0 || s.PeriodsPerDay < 1 {
+		return nil, errors.New("scheduler needs at least one day and one period")
+	}
+	if len(s.Rooms) == 0 {
+		return nil, errors.New("scheduler needs at least one room")
+	}
+
+	state := newScheduleState(s)
+	if state.search(0) {
+		return &Timetable{Sessions: state.placed}, nil
+	}
+
+	// No complete timetable: place what fits first come, first served and
+	// explain what blocked the rest
+	state = newScheduleState(s)
+	timetable := &Timetable{}
+	for _, pending := range state.pending {
+		if slot, room, ok := state.firstFit(pending); ok {
+			state.place(pending, slot, room)
+			continue
+		}
+		timetable.Conflicts = append(timetable.Conflicts, state.explain(pending))
+	}
+	timetable.Sessions = state.placed
+	return timetable, nil
+}
+
+// A course session waiting for a slot
+type pendingSession struct {
+	course *Course
+	number int
+}
+
+// Bookkeeping for one run of the scheduler
+type scheduleState struct {
+	slots   []TimeSlot
+	rooms   []Room
+	pending []pendingSession
+	placed  []ScheduledSession
+	steps   int
+
+	teacherBusy map[string]map[TimeSlot]bool
+	studentBusy map[string]map[TimeSlot]bool
+	roomBusy    map[string]map[TimeSlot]bool
+	courseDays  map[string]map[time.Weekday]bool
+}
+
+func newScheduleState(s *Scheduler) *scheduleState {
+	state := &scheduleState{
+		teacherBusy: make(map[string]map[TimeSlot]bool),
+		studentBusy: make(map[string]map[TimeSlot]bool),
+		roomBusy:    make(map[string]map[TimeSlot]bool),
+		courseDays:  make(map[string]map[time.Weekday]bool),
+	}
+	for _, day := range s.Days {
+		for period := 1; period <= s.PeriodsPerDay; period++ {
+			state.slots = append(state.slots, TimeSlot{Day: day, Period: period})
+		}
+	}
+
+	// Smallest rooms first so large rooms stay free for large courses
+	state.rooms = append([]Room(nil), s.Rooms...)
+	sort.SliceStable(state.rooms, func(i, j int) bool { return state.rooms[i].Capacity < state.rooms[j].Capacity })
+
+	// Most constrained courses first
+	for i := range s.Courses {
+		for number := 1; number <= s.Courses[i].SessionsPerWeek; number++ {
+			state.pending = append(state.pending, pendingSession{course: &s.Courses[i], number: number})
+		}
+	}
+	sort.SliceStable(state.pending, func(i, j int) bool {
+		return len(state.pending[i].course.Students) > len(state.pending[j].course.Students)
+	})
+	return state
+}
+
+// Places the pending sessions from index i on; false when they cannot all be placed
+func (st *scheduleState) search(i int) bool {
+	if i == len(st.pending) {
+		return true
+	}
+	for _, slot := range st.slots {
+		for _, room := range st.rooms {
+			st.steps++
+			if st.steps > maxSearchSteps {
+				return false
+			}
+			if !st.fits(st.pending[i], slot, room) {
+				continue
+			}
+			st.place(st.pending[i], slot, room)
+			if st.search(i + 1) {
+				return true
+			}
+			st.unplace(st.pending[i], slot, room)
+		}
+	}
+	return false
+}
+
+// Returns the first slot and room where a session fits
+func (st *scheduleState) firstFit(p pendingSession) (TimeSlot, Room, bool) {
+	for _, slot := range st.slots {
+		for _, room := range st.rooms {
+			if st.fits(p, slot, room) {
+				return slot, room, true
+			}
+		}
+	}
+	return TimeSlot{}, Room{}, false
+}
+
+// Checks if a session can be placed in a slot and room
+func (st *scheduleState) fits(p pendingSession, slot TimeSlot, room Room) bool {
+	if room.Capacity < len(p.course.Students) || st.roomBusy[room.Name][slot] {
+		return false
+	}
+	if st.courseDays[p.course.Name][slot.Day] || st.teacherBusy[p.course.Teacher][slot] {
+		return false
+	}
+	for _, student := range p.course.Students {
+		if st.studentBusy[studentKey(student)][slot] {
+			return false
+		}
+	}
+	return true
+}
+
+func (st *scheduleState) place(p pendingSession, slot TimeSlot, room Room) {
+	st.mark(p, slot, room, true)
+	st.placed = append(st.placed, ScheduledSession{
+		Course:   p.course.Name,
+		Teacher:  p.course.Teacher,
+		Room:     room.Name,
+		Slot:     slot,
+		Students: p.course.Students,
+	})
+}
+
+func (st *scheduleState) unplace(p pendingSession, slot TimeSlot, room Room) {
+	st.mark(p, slot, room, false)
+	st.placed = st.placed[:len(st.placed)-1]
+}
+
+// Books or frees the teacher, students, room and day of a session
+func (st *scheduleState) mark(p pendingSession, slot TimeSlot, room Room, busy bool) {
+	setBusy(st.roomBusy, room.Name, slot, busy)
+	setBusy(st.teacherBusy, p.course.Teacher, slot, busy)
+	for _, student := range p.course.Students {
+		setBusy(st.studentBusy, studentKey(student), slot, busy)
+	}
+	if st.courseDays[p.course.Name] == nil {
+		st.courseDays[p.course.Name] = make(map[time.Weekday]bool)
+	}
+	st.courseDays[p.course.Name][slot.Day] = busy
+}
+
+// Lists the constraints that block a session in each slot
+func (st *scheduleState) explain(p pendingSession) Conflict {
+	conflict := Conflict{Course: p.course.Name, Session: p.number}
+
+	largeEnough := false
+	for _, room := range st.rooms {
+		if room.Capacity >= len(p.course.Students) {
+			largeEnough = true
+		}
+	}
+	if !largeEnough {
+		conflict.Reasons = append(conflict.Reasons, ConflictReason{Kind: NoRoomLargeEnough, Slots: len(st.slots)})
+		return conflict
+	}
+
+	counts := make(map[ConflictReason]int)
+	var order []ConflictReason
+	count := func(reason ConflictReason) {
+		if counts[reason] == 0 {
+			order = append(order, reason)
+		}
+		counts[reason]++
+	}
+	for _, slot := range st.slots {
+		if st.courseDays[p.course.Name][slot.Day] {
+			count(ConflictReason{Kind: CourseMeetsThatDay})
+			continue
+		}
+		if st.teacherBusy[p.course.Teacher][slot] {
+			count(ConflictReason{Kind: TeacherBusy, Subject: p.course.Teacher})
+		}
+		for _, student := range p.course.Students {
+			if st.studentBusy[studentKey(student)][slot] {
+				count(ConflictReason{Kind: StudentBusy, Subject: student.Name})
+			}
+		}
+		roomFree := false
+		for _, room := range st.rooms {
+			if room.Capacity >= len(p.course.Students) && !st.roomBusy[room.Name][slot] {
+				roomFree = true
+			}
+		}
+		if !roomFree {
+			count(ConflictReason{Kind: RoomsTaken})
+		}
+	}
+	for _, reason := range order {
+		reason.Slots = counts[reason]
+		conflict.Reasons = append(conflict.Reasons, reason)
+	}
+	return conflict
+}
+
+func setBusy(busy map[string]map[TimeSlot]bool, key string, slot TimeSlot, value bool) {
+	if busy[key] == nil {
+		busy[key] = make(map[TimeSlot]bool)
+	}
+	busy[key][slot] = value
+}
+
+// weekdayNames holds the day names used in each locale, starting on Sunday
+var weekdayNames = map[Locale][7]string{
+	English: {"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+	Spanish: {"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+}
+
+// Weekday returns the name of a day of the week in the locale of the
+// translator
+func (t *Translator) Weekday(day time.Weekday) string {
+	return weekdayNames[t.locale][day]
+}
+
+// Orders the week from Monday to Sunday
+func weekdayOrder(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+// RenderStudentTimetable writes the timetable of a student, by name or
+// student number, to w with the same formats as the roster reports
+func RenderStudentTimetable(w io.Writer, timetable *Timetable, key string, format ReportFormat, tr *Translator) error {
+	sessions, err := timetable.ForStudent(key)
+	if err != nil {
+		return err
+	}
+	columns := []string{"Day", "Period", "Course", "Teacher", "Room"}
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column
+		if tr != nil && format != CSVFormat && format != JSONFormat {
+			header[i] = tr.T("column." + column)
+		}
+	}
+	var cells [][]string
+	for _, session := range sessions {
+		day := session.Slot.Day.String()
+		if tr != nil && format != CSVFormat && format != JSONFormat {
+			day = tr.Weekday(session.Slot.Day)
+		}
+		cells = append(cells, []string{day, strconv.Itoa(session.Slot.Period), session.Course, session.Teacher, session.Room})
+	}
+	return RenderTable(w, format, header, cells)
+}
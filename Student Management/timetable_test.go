@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Returns students numbered S1, S2 and so on with the given names
+func courseStudents(names ...string) []*Student {
+	students := make([]*Student, len(names))
+	for i, name := range names {
+		students[i] = &Student{Number: fmt.Sprintf("S%d", i+1), Name: name}
+	}
+	return students
+}
+
+func TestSchedulerAvoidsDoubleBooking(t *testing.T) {
+	s := courseStudents("Alice", "Bob", "Charlie", "Dana")
+	alice, bob, charlie, dana := s[0], s[1], s[2], s[3]
+	scheduler := &Scheduler{
+		Days:          []time.Weekday{time.Monday, time.Tuesday, time.Wednesday},
+		PeriodsPerDay: 2,
+		Rooms:         []Room{{Name: "Lab", Capacity: 2}, {Name: "Hall", Capacity: 3}},
+		Courses: []Course{
+			{Name: "Math", Teacher: "Smith", SessionsPerWeek: 2, Students: []*Student{alice, bob}},
+			{Name: "Physics", Teacher: "Jones", SessionsPerWeek: 2, Students: []*Student{alice, charlie}},
+			{Name: "History", Teacher: "Smith", SessionsPerWeek: 2, Students: []*Student{bob, charlie, dana}},
+		},
+	}
+
+	timetable, err := scheduler.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !timetable.Complete() {
+		t.Fatalf("expected a complete timetable, got conflicts %v", timetable.Conflicts)
+	}
+	if len(timetable.Sessions) != 6 {
+		t.Errorf("expected 6 sessions, got %d", len(timetable.Sessions))
+	}
+
+	booked := make(map[string]bool)
+	for _, session := range timetable.Sessions {
+		keys := []string{"room " + session.Room, "teacher " + session.Teacher}
+		for _, student := range session.Students {
+			keys = append(keys, "student "+student.Number)
+		}
+		for _, key := range keys {
+			slotKey := fmt.Sprintf("%s %s %d", key, session.Slot.Day, session.Slot.Period)
+			if booked[slotKey] {
+				t.Errorf("%s is double-booked on %s period %d", key, session.Slot.Day, session.Slot.Period)
+			}
+			booked[slotKey] = true
+		}
+	}
+}
+
+func TestSchedulerReportsConflicts(t *testing.T) {
+	s := courseStudents("Alice", "Bob")
+	alice, bob := s[0], s[1]
+	scheduler := &Scheduler{
+		Days:          []time.Weekday{time.Monday},
+		PeriodsPerDay: 1,
+		Rooms:         []Room{{Name: "Lab", Capacity: 1}},
+		Courses: []Course{
+			{Name: "Math", Teacher: "Smith", SessionsPerWeek: 1, Students: []*Student{alice}},
+			{Name: "Physics", Teacher: "Smith", SessionsPerWeek: 1, Students: []*Student{bob}},
+			{Name: "History", Teacher: "Jones", SessionsPerWeek: 1, Students: []*Student{alice, bob}},
+		},
+	}
+
+	timetable, err := scheduler.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timetable.Complete() {
+		t.Fatal("expected conflicts, got a complete timetable")
+	}
+
+	kinds := make(map[ConflictKind]bool)
+	for _, conflict := range timetable.Conflicts {
+		for _, reason := range conflict.Reasons {
+			kinds[reason.Kind] = true
+		}
+	}
+	if !kinds[NoRoomLargeEnough] || !kinds[TeacherBusy] {
+		t.Errorf("expected room capacity and teacher conflicts, got %v", timetable.Conflicts)
+	}
+}
+
+func TestTimetableTellsStudentsWithTheSameNameApart(t *testing.T) {
+	s := courseStudents("Alice", "Alice", "Bob")
+	scheduler := &Scheduler{
+		Days:          []time.Weekday{time.Monday},
+		PeriodsPerDay: 2,
+		Rooms:         []Room{{Name: "Lab", Capacity: 2}},
+		Courses: []Course{
+			{Name: "Math", Teacher: "Smith", SessionsPerWeek: 1, Students: []*Student{s[0], s[2]}},
+			{Name: "Physics", Teacher: "Jones", SessionsPerWeek: 1, Students: []*Student{s[1], s[2]}},
+		},
+	}
+
+	timetable, err := scheduler.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := timetable.ForStudent("Alice"); !errors.Is(err, ErrAmbiguousStudent) {
+		t.Errorf("expected an ambiguous name to be refused, got %v", err)
+	}
+	sessions, err := timetable.ForStudent("S2")
+	if err != nil || len(sessions) != 1 || sessions[0].Course != "Physics" {
+		t.Errorf("expected the number to find the second Alice's course, got %v, %v", sessions, err)
+	}
+	if sessions, err := timetable.ForStudent("Bob"); err != nil || len(sessions) != 2 {
+		t.Errorf("expected a unique name to find both courses, got %v, %v", sessions, err)
+	}
+}
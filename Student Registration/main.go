@@ -1,7 +1,10 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
+	"os"
 	"time"
 )
 
@@ -16,16 +19,27 @@ type Student struct {
 }
 
 func main() {
-	// Create a slice to hold registered students
-	var students []Student
+	interactive := flag.Bool("interactive", false, "register students with an interactive wizard")
+	flag.Parse()
+
+	// Create a registry to hold registered students
+	registry := NewStudentRegistry()
+
+	if *interactive {
+		if err := NewWizard(os.Stdin, os.Stdout, registry).Run(); err != nil {
+			fmt.Println("Error:", err)
+		}
+		displayStudents(registry.Students())
+		return
+	}
 
 	// Register students
-	students = append(students, registerStudent("Alice", 2003, true))
-	students = append(students, registerStudent("Bob", 2005, true))
-	students = append(students, registerStudent("Charlie", 1999, false))
+	registry.Register("Alice", 2003, true)
+	registry.Register("Bob", 2005, true)
+	registry.Register("Charlie", 1999, false)
 
 	// Display registered students
-	displayStudents(students)
+	displayStudents(registry.Students())
 }
 
 // Registers a new student
@@ -46,8 +60,13 @@ func isAdult(age int) bool {
 
 // Displays information about registered students
 func displayStudents(students []Student) {
+	writeStudents(os.Stdout, students)
+}
+
+// Writes information about registered students to w
+func writeStudents(w io.Writer, students []Student) {
 	for _, student := range students {
 		age := calculateAge(student.BirthYear)
-		fmt.Printf("Name: %s, Age: %d, Active: %t, Adult: %t\n", student.Name, age, student.IsActive, isAdult(age))
+		fmt.Fprintf(w, "Name: %s, Age: %d, Active: %t, Adult: %t\n", student.Name, age, student.IsActive, isAdult(age))
 	}
 }
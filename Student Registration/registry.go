@@ -0,0 +1,92 @@
+package main
+
+import (
+	"errors"
+	"sync"
+)
+
+// StudentRegistry struct
+type StudentRegistry struct {
+	students []Student
+	mu       sync.RWMutex
+}
+
+// NewStudentRegistry creates a new StudentRegistry
+func NewStudentRegistry() *StudentRegistry {
+	return &StudentRegistry{}
+}
+
+// Register registers a new student and adds it to the registry
+func (r *StudentRegistry) Register(name string, birthYear int, isActive bool) Student {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	student := registerStudent(name, birthYear, isActive)
+	r.students = append(r.students, student)
+	return student
+}
+
+// Students returns a snapshot of the registered students
+func (r *StudentRegistry) Students() []Student {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	students := make([]Student, len(r.students))
+	copy(students, r.students)
+	return students
+}
+
+// SetActive activates or deactivates a student by name
+func (r *StudentRegistry) SetActive(name string, isActive bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.students {
+		if r.students[i].Name == name {
+			r.students[i].IsActive = isActive
+			return nil
+		}
+	}
+	return errors.New("student not found")
+}
+
+// Replace replaces the student with the given name
+func (r *StudentRegistry) Replace(name string, student Student) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.students {
+		if r.students[i].Name == name {
+			r.students[i] = student
+			return nil
+		}
+	}
+	return errors.New("student not found")
+}
+
+// Remove removes the student with the given name
+func (r *StudentRegistry) Remove(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i := range r.students {
+		if r.students[i].Name == name {
+			r.students = append(r.students[:i], r.students[i+1:]...)
+			return nil
+		}
+	}
+	return errors.New("student not found")
+}
+
+// Checks if a student with the given name is registered
+func (r *StudentRegistry) exists(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, student := range r.students {
+		if student.Name == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRegistryConcurrentRegistrations(t *testing.T) {
+	registry := NewStudentRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			registry.Register(fmt.Sprintf("Student %d", i), 2000, true)
+			registry.Students()
+		}(i)
+	}
+	wg.Wait()
+
+	if students := registry.Students(); len(students) != 50 {
+		t.Errorf("expected 50 students, got %d", len(students))
+	}
+}
+
+func TestRegistryConcurrentSetActive(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register("Alice", 2003, true)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(2)
+		go func(active bool) {
+			defer wg.Done()
+			if err := registry.SetActive("Alice", active); err != nil {
+				t.Error(err)
+			}
+		}(i%2 == 0)
+		go func() {
+			defer wg.Done()
+			for _, student := range registry.Students() {
+				_ = student.IsActive
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRegistrySnapshotIsACopy(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register("Bob", 2005, true)
+
+	students := registry.Students()
+	students[0].IsActive = false
+
+	if !registry.Students()[0].IsActive {
+		t.Error("expected changes to a snapshot not to affect the registry")
+	}
+}
+
+func TestRegistryUnknownStudent(t *testing.T) {
+	registry := NewStudentRegistry()
+	if err := registry.SetActive("Nobody", false); err == nil {
+		t.Error("expected error when updating an unknown student, got nil")
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Oldest birth year the wizard accepts
+const minBirthYear = 1900
+
+// errCancelled is returned when the user declines to save an entry
+var errCancelled = errors.New("cancelled")
+
+// Wizard registers students interactively, one field at a time
+type Wizard struct {
+	in       *bufio.Scanner
+	out      io.Writer
+	registry *StudentRegistry
+	undo     []undoEntry
+}
+
+// A change that can be undone. previous is nil when the student was added.
+type undoEntry struct {
+	name     string
+	previous *Student
+}
+
+// NewWizard creates a new Wizard that reads answers from in and writes
+// prompts to out
+func NewWizard(in io.Reader, out io.Writer, registry *StudentRegistry) *Wizard {
+	return &Wizard{in: bufio.NewScanner(in), out: out, registry: registry}
+}
+
+// Run shows the menu until the user quits or the input ends
+func (w *Wizard) Run() error {
+	for {
+		fmt.Fprintln(w.out, "\n[a]dd  [e]dit  [u]ndo  [l]ist  [q]uit")
+		command, err := w.prompt("Choice", "")
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch strings.ToLower(command) {
+		case "a", "add":
+			err = w.add()
+		case "e", "edit":
+			err = w.edit()
+		case "u", "undo":
+			w.undoLast()
+		case "l", "list":
+			writeStudents(w.out, w.registry.Students())
+		case "q", "quit":
+			return nil
+		default:
+			fmt.Fprintf(w.out, "Unknown choice %q\n", command)
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil && err != errCancelled {
+			return err
+		}
+	}
+}
+
+// Prompts for a new student and registers it after confirmation
+func (w *Wizard) add() error {
+	student, err := w.readStudent(Student{IsActive: true}, "")
+	if err != nil {
+		return err
+	}
+	w.registry.Register(student.Name, student.BirthYear, student.IsActive)
+	w.undo = append(w.undo, undoEntry{name: student.Name})
+	fmt.Fprintf(w.out, "Registered %s\n", student.Name)
+	return nil
+}
+
+// Prompts for changes to a registered student and saves them after confirmation
+func (w *Wizard) edit() error {
+	var current Student
+	for {
+		name, err := w.prompt("Name of the student to edit", "")
+		if err != nil {
+			return err
+		}
+		if student, found := w.find(name); found {
+			current = student
+			break
+		}
+		fmt.Fprintf(w.out, "No student named %q\n", name)
+	}
+
+	student, err := w.readStudent(current, current.Name)
+	if err != nil {
+		return err
+	}
+	if err := w.registry.Replace(current.Name, student); err != nil {
+		return err
+	}
+	w.undo = append(w.undo, undoEntry{name: student.Name, previous: &current})
+	fmt.Fprintf(w.out, "Updated %s\n", student.Name)
+	return nil
+}
+
+// Reverts the last add or edit
+func (w *Wizard) undoLast() {
+	if len(w.undo) == 0 {
+		fmt.Fprintln(w.out, "Nothing to undo")
+		return
+	}
+	entry := w.undo[len(w.undo)-1]
+	w.undo = w.undo[:len(w.undo)-1]
+
+	if entry.previous == nil {
+		w.registry.Remove(entry.name)
+		fmt.Fprintf(w.out, "Removed %s\n", entry.name)
+		return
+	}
+	w.registry.Replace(entry.name, *entry.previous)
+	fmt.Fprintf(w.out, "Restored %s\n", entry.previous.Name)
+}
+
+// Prompts for every field, starting from the values of defaults, and asks
+// for confirmation. originalName is the name being edited, if any.
+func (w *Wizard) readStudent(defaults Student, originalName string) (Student, error) {
+	name, err := w.readField("Name", defaults.Name, func(value string) (string, error) {
+		if value == "" {
+			return "", errors.New("name is required")
+		}
+		if value != originalName && w.registry.exists(value) {
+			return "", fmt.Errorf("%s is already registered", value)
+		}
+		return value, nil
+	})
+	if err != nil {
+		return Student{}, err
+	}
+
+	birthYearDefault := ""
+	if defaults.BirthYear != 0 {
+		birthYearDefault = strconv.Itoa(defaults.BirthYear)
+	}
+	birthYearText, err := w.readField("Birth year", birthYearDefault, func(value string) (string, error) {
+		year, err := strconv.Atoi(value)
+		if err != nil {
+			return "", errors.New("birth year must be a number")
+		}
+		if year < minBirthYear || year > time.Now().Year() {
+			return "", fmt.Errorf("birth year must be between %d and %d", minBirthYear, time.Now().Year())
+		}
+		age := calculateAge(year)
+		fmt.Fprintf(w.out, "  Age: %d, Adult: %t\n", age, isAdult(age))
+		return value, nil
+	})
+	if err != nil {
+		return Student{}, err
+	}
+	birthYear, _ := strconv.Atoi(birthYearText)
+
+	isActive, err := w.readYesNo("Active", defaults.IsActive)
+	if err != nil {
+		return Student{}, err
+	}
+
+	student := registerStudent(name, birthYear, isActive)
+	age := calculateAge(student.BirthYear)
+	fmt.Fprintf(w.out, "Name: %s, Age: %d, Active: %t, Adult: %t\n", student.Name, age, student.IsActive, isAdult(age))
+	save, err := w.readYesNo("Save", true)
+	if err != nil {
+		return Student{}, err
+	}
+	if !save {
+		fmt.Fprintln(w.out, "Discarded")
+		return Student{}, errCancelled
+	}
+	return student, nil
+}
+
+// Prompts until validate accepts the answer
+func (w *Wizard) readField(label, defaultValue string, validate func(string) (string, error)) (string, error) {
+	for {
+		answer, err := w.prompt(label, defaultValue)
+		if err != nil {
+			return "", err
+		}
+		value, err := validate(answer)
+		if err == nil {
+			return value, nil
+		}
+		fmt.Fprintf(w.out, "  %v\n", err)
+	}
+}
+
+// Prompts until the answer is yes or no
+func (w *Wizard) readYesNo(label string, defaultValue bool) (bool, error) {
+	defaultAnswer := "n"
+	if defaultValue {
+		defaultAnswer = "y"
+	}
+	answer, err := w.readField(label+" (y/n)", defaultAnswer, func(value string) (string, error) {
+		switch strings.ToLower(value) {
+		case "y", "yes":
+			return "y", nil
+		case "n", "no":
+			return "n", nil
+		}
+		return "", errors.New("answer y or n")
+	})
+	return answer == "y", err
+}
+
+// Writes a prompt and reads one trimmed line; an empty line gives defaultValue
+func (w *Wizard) prompt(label, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Fprintf(w.out, "%s [%s]: ", label, defaultValue)
+	} else {
+		fmt.Fprintf(w.out, "%s: ", label)
+	}
+	if !w.in.Scan() {
+		if err := w.in.Err(); err != nil {
+			return "", err
+		}
+		return "", io.EOF
+	}
+	answer := strings.TrimSpace(w.in.Text())
+	if answer == "" {
+		return defaultValue, nil
+	}
+	return answer, nil
+}
+
+// Finds a registered student by name
+func (w *Wizard) find(name string) (Student, bool) {
+	for _, student := range w.registry.Students() {
+		if student.Name == name {
+			return student, true
+		}
+	}
+	return Student{}, false
+}
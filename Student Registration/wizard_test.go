@@ -0,0 +1,37 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWizardAddEditAndUndo(t *testing.T) {
+	registry := NewStudentRegistry()
+	registry.Register("Alice", 2003, true)
+
+	input := strings.Join([]string{
+		// Add Bob, retrying an invalid name and birth year
+		"a", "Alice", "Bob", "year", "1800", "2005", "", "y",
+		// Add Charlie but discard him
+		"a", "Charlie", "1999", "n", "n",
+		// Rename Bob and deactivate him, then undo the edit
+		"e", "Robert", "Bob", "Rob", "", "n", "y",
+		"u",
+		// Add Dana, then undo the add
+		"a", "Dana", "2010", "y", "",
+		"u",
+		"q",
+	}, "\n")
+	if err := NewWizard(strings.NewReader(input), io.Discard, registry).Run(); err != nil {
+		t.Fatal(err)
+	}
+
+	students := registry.Students()
+	if len(students) != 2 {
+		t.Fatalf("expected 2 students, got %v", students)
+	}
+	if bob := students[1]; bob.Name != "Bob" || bob.BirthYear != 2005 || !bob.IsActive {
+		t.Errorf("expected Bob restored by undo, got %+v", bob)
+	}
+}
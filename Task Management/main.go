@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"sync"
 	"time"
@@ -94,14 +95,18 @@ func (tm *TaskManager) worker(wg *sync.WaitGroup, tasks chan int) {
 	defer wg.Done()
 
 	for id := range tasks {
-		fmt.Printf("Processing task %d\n", id)
+		fmt.Println(translator.T("task.processing", id))
 		time.Sleep(1 * time.Second) // Simulate task processing
 		tm.CompleteTask(id)
-		fmt.Printf("Task %d completed\n", id)
+		fmt.Println(translator.T("task.completed", id))
 	}
 }
 
 func main() {
+	lang := flag.String("lang", "", "language of the messages (en or es); defaults to LC_ALL, LC_MESSAGES or LANG")
+	flag.Parse()
+	translator = NewTranslator(SelectLocale(*lang))
+
 	tm := NewTaskManager()
 
 	// Add tasks
@@ -110,13 +115,15 @@ func main() {
 	tm.AddTask("Write a blog post")
 
 	// Process tasks concurrently
-	fmt.Println("Processing tasks...")
-	tm.ProcessTasks([]int{1, 2, 3})
+	ids := []int{1, 2, 3}
+	fmt.Println(translator.N("tasks.processing", len(ids), len(ids)))
+	tm.ProcessTasks(ids)
 
 	// List completed tasks
-	fmt.Println("Completed tasks:")
-	for _, task := range tm.ListTasks(true) {
-		fmt.Printf("ID: %d, Description: %s, Completed: %t, CreatedAt: %s\n",
-			task.ID, task.Description, task.Completed, task.CreatedAt)
+	completed := tm.ListTasks(true)
+	fmt.Println(translator.N("tasks.completed", len(completed), len(completed)))
+	for _, task := range completed {
+		fmt.Println(translator.T("task.entry",
+			task.ID, task.Description, translator.Bool(task.Completed), translator.Date(task.CreatedAt)))
 	}
 }
@@ -0,0 +1,26 @@
+package main
+
+// translator formats the console messages; main selects its locale
+var translator = NewTranslator(DefaultLocale)
+
+// catalogs holds the messages of every supported locale
+var catalogs = map[Locale]map[string]message{
+	English: {
+		"bool.true":        {Other: "true"},
+		"bool.false":       {Other: "false"},
+		"tasks.processing": {One: "Processing %d task...", Other: "Processing %d tasks..."},
+		"task.processing":  {Other: "Processing task %d"},
+		"task.completed":   {Other: "Task %d completed"},
+		"tasks.completed":  {One: "%d completed task:", Other: "%d completed tasks:"},
+		"task.entry":       {Other: "ID: %d, Description: %s, Completed: %s, CreatedAt: %s"},
+	},
+	Spanish: {
+		"bool.true":        {Other: "sí"},
+		"bool.false":       {Other: "no"},
+		"tasks.processing": {One: "Procesando %d tarea...", Other: "Procesando %d tareas..."},
+		"task.processing":  {Other: "Procesando la tarea %d"},
+		"task.completed":   {Other: "Tarea %d completada"},
+		"tasks.completed":  {One: "%d tarea completada:", Other: "%d tareas completadas:"},
+		"task.entry":       {Other: "ID: %d, Descripción: %s, Completada: %s, Creada: %s"},
+	},
+}